@@ -128,6 +128,7 @@ func (a *aggregator) exec(t time.Time) {
 }
 
 func (a *aggregator) aggregate(t time.Time) {
+	defer recoverPanic(a.logger, "aggregator")
 	defer func() { <-a.sema /* release token */ }()
 	for _, qname := range a.queues {
 		groups, err := a.broker.ListGroups(qname)
@@ -25,6 +25,7 @@ func TestClientEnqueueWithProcessAtOption(t *testing.T) {
 
 	var (
 		now          = time.Now()
+		oneHourAgo   = now.Add(-time.Hour)
 		oneHourLater = now.Add(time.Hour)
 	)
 
@@ -71,6 +72,40 @@ func TestClientEnqueueWithProcessAtOption(t *testing.T) {
 				"default": {},
 			},
 		},
+		{
+			desc:      "Process task immediately if processAt is in the past",
+			task:      task,
+			processAt: oneHourAgo,
+			opts:      []Option{},
+			wantInfo: &TaskInfo{
+				Queue:         "default",
+				Type:          task.Type(),
+				Payload:       task.Payload(),
+				State:         TaskStatePending,
+				MaxRetry:      defaultMaxRetry,
+				Retried:       0,
+				LastErr:       "",
+				LastFailedAt:  time.Time{},
+				Timeout:       defaultTimeout,
+				Deadline:      time.Time{},
+				NextProcessAt: now,
+			},
+			wantPending: map[string][]*base.TaskMessage{
+				"default": {
+					{
+						Type:     task.Type(),
+						Payload:  task.Payload(),
+						Retry:    defaultMaxRetry,
+						Queue:    "default",
+						Timeout:  int64(defaultTimeout.Seconds()),
+						Deadline: noDeadline.Unix(),
+					},
+				},
+			},
+			wantScheduled: map[string][]base.Z{
+				"default": {},
+			},
+		},
 		{
 			desc:      "Schedule task to be processed in the future",
 			task:      task,
@@ -1176,3 +1211,18 @@ func TestClientEnqueueUniqueWithProcessAtOption(t *testing.T) {
 		}
 	}
 }
+
+func TestClientFromRedisClient(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	client := NewClientFromRedisClient(r)
+	if _, err := client.Enqueue(NewTask("send_email", nil)); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if err := client.Close(); err == nil {
+		t.Error("Close() = nil, want an error for a shared redis connection")
+	}
+	if err := r.Ping(context.Background()).Err(); err != nil {
+		t.Errorf("redis client returned error %v after Close, want the connection to stay open", err)
+	}
+}
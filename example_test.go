@@ -99,6 +99,35 @@ func ExampleScheduler() {
 	}
 }
 
+func ExampleServeMux_Use() {
+	// loggingMiddleware logs the type of each task and how long the handler took to process it.
+	loggingMiddleware := func(h asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			start := time.Now()
+			err := h.ProcessTask(ctx, t)
+			log.Printf("processed task type=%q duration=%v err=%v", t.Type(), time.Since(start), err)
+			return err
+		})
+	}
+
+	mux := asynq.NewServeMux()
+	// Middlewares are applied to all handlers registered with the mux,
+	// and are invoked in the order they are passed to Use.
+	mux.Use(loggingMiddleware)
+	mux.HandleFunc("email:welcome", func(ctx context.Context, t *asynq.Task) error {
+		// ... send welcome email
+		return nil
+	})
+
+	srv := asynq.NewServer(
+		asynq.RedisClientOpt{Addr: ":6379"},
+		asynq.Config{Concurrency: 20},
+	)
+	if err := srv.Run(mux); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func ExampleParseRedisURI() {
 	rconn, err := asynq.ParseRedisURI("redis://localhost:6379/10")
 	if err != nil {
@@ -71,6 +71,7 @@ func (f *forwarder) start(wg *sync.WaitGroup) {
 }
 
 func (f *forwarder) exec() {
+	defer recoverPanic(f.logger, "forwarder")
 	if err := f.broker.ForwardIfReady(f.queues...); err != nil {
 		f.logger.Errorf("Failed to forward scheduled tasks: %v", err)
 	}
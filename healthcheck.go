@@ -71,10 +71,15 @@ func (hc *healthchecker) start(wg *sync.WaitGroup) {
 				timer.Stop()
 				return
 			case <-timer.C:
-				err := hc.broker.Ping()
-				hc.healthcheckFunc(err)
+				hc.exec()
 				timer.Reset(hc.interval)
 			}
 		}
 	}()
 }
+
+func (hc *healthchecker) exec() {
+	defer recoverPanic(hc.logger, "healthchecker")
+	err := hc.broker.Ping()
+	hc.healthcheckFunc(err)
+}
@@ -32,10 +32,13 @@ type heartbeater struct {
 	host           string
 	pid            int
 	serverID       string
-	concurrency    int
 	queues         map[string]int
 	strictPriority bool
 
+	// concurrency can be updated via setConcurrency, so access is guarded by mu.
+	mu          sync.Mutex
+	concurrency int
+
 	// following fields are mutable and should be accessed only by the
 	// heartbeater goroutine. In other words, confine these variables
 	// to this goroutine only.
@@ -89,6 +92,13 @@ func newHeartbeater(params heartbeaterParams) *heartbeater {
 	}
 }
 
+// setConcurrency updates the concurrency reported in the server info.
+func (h *heartbeater) setConcurrency(n int) {
+	h.mu.Lock()
+	h.concurrency = n
+	h.mu.Unlock()
+}
+
 func (h *heartbeater) shutdown() {
 	h.logger.Debug("Heartbeater shutting down...")
 	// Signal the heartbeater goroutine to stop.
@@ -141,15 +151,20 @@ func (h *heartbeater) start(wg *sync.WaitGroup) {
 
 // beat extends lease for workers and writes server/worker info to redis.
 func (h *heartbeater) beat() {
+	defer recoverPanic(h.logger, "heartbeater")
 	h.state.mu.Lock()
 	srvStatus := h.state.value.String()
 	h.state.mu.Unlock()
 
+	h.mu.Lock()
+	concurrency := h.concurrency
+	h.mu.Unlock()
+
 	info := base.ServerInfo{
 		Host:              h.host,
 		PID:               h.pid,
 		ServerID:          h.serverID,
-		Concurrency:       h.concurrency,
+		Concurrency:       concurrency,
 		Queues:            h.queues,
 		StrictPriority:    h.strictPriority,
 		Status:            srvStatus,
@@ -3523,3 +3523,18 @@ func TestInspectorGroups(t *testing.T) {
 		})
 	}
 }
+
+func TestInspectorFromRedisClient(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	inspector := NewInspectorFromRedisClient(r)
+	if _, err := inspector.Queues(); err != nil {
+		t.Fatalf("Queues returned error: %v", err)
+	}
+	if err := inspector.Close(); err == nil {
+		t.Error("Close() = nil, want an error for a shared redis connection")
+	}
+	if err := r.Ping(context.Background()).Err(); err != nil {
+		t.Errorf("redis client returned error %v after Close, want the connection to stay open", err)
+	}
+}
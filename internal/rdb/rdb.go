@@ -212,7 +212,7 @@ func (r *RDB) EnqueueUnique(ctx context.Context, msg *base.TaskMessage, ttl time
 //
 // Output:
 // Returns nil if no processable task is found in the given queue.
-// Returns an encoded TaskMessage.
+// Returns a table containing the task ID and the encoded TaskMessage.
 //
 // Note: dequeueCmd checks whether a queue is paused first, before
 // calling RPOPLPUSH to pop a task from the queue.
@@ -224,7 +224,7 @@ if redis.call("EXISTS", KEYS[2]) == 0 then
 		redis.call("HSET", key, "state", "active")
 		redis.call("HDEL", key, "pending_since")
 		redis.call("ZADD", KEYS[4], ARGV[1], id)
-		return redis.call("HGET", key, "msg")
+		return {id, redis.call("HGET", key, "msg")}
 	end
 end
 return nil`)
@@ -232,6 +232,7 @@ return nil`)
 // Dequeue queries given queues in order and pops a task message
 // off a queue if one exists and returns the message and its lease expiration time.
 // Dequeue skips a queue if the queue is paused.
+// A task whose message cannot be decoded is moved to the archive and skipped.
 // If all queues are empty, ErrNoProcessableTask error is returned.
 func (r *RDB) Dequeue(qnames ...string) (msg *base.TaskMessage, leaseExpirationTime time.Time, err error) {
 	var op errors.Op = "rdb.Dequeue"
@@ -253,18 +254,28 @@ func (r *RDB) Dequeue(qnames ...string) (msg *base.TaskMessage, leaseExpirationT
 		} else if err != nil {
 			return nil, time.Time{}, errors.E(op, errors.Unknown, fmt.Sprintf("redis eval error: %v", err))
 		}
-		encoded, err := cast.ToStringE(res)
-		if err != nil {
+		data, err := cast.ToStringSliceE(res)
+		if err != nil || len(data) != 2 {
 			return nil, time.Time{}, errors.E(op, errors.Internal, fmt.Sprintf("cast error: unexpected return value from Lua script: %v", res))
 		}
-		if msg, err = base.DecodeMessage([]byte(encoded)); err != nil {
-			return nil, time.Time{}, errors.E(op, errors.Internal, fmt.Sprintf("cannot decode message: %v", err))
+		if msg, err = base.DecodeMessage([]byte(data[1])); err != nil {
+			if err := r.archiveMalformed(qname, data[0], data[1], err); err != nil {
+				return nil, time.Time{}, errors.E(op, errors.CanonicalCode(err), err)
+			}
+			continue
 		}
 		return msg, leaseExpirationTime, nil
 	}
 	return nil, time.Time{}, errors.E(op, errors.NotFound, errors.ErrNoProcessableTask)
 }
 
+// archiveMalformed moves an active task whose message cannot be decoded to the archive.
+// The undecodable data is kept as the payload of the archived task so that it can be inspected.
+func (r *RDB) archiveMalformed(qname, id, data string, decodeErr error) error {
+	msg := &base.TaskMessage{ID: id, Queue: qname, Payload: []byte(data)}
+	return r.Archive(context.Background(), msg, fmt.Sprintf("malformed message: %v", decodeErr))
+}
+
 // KEYS[1] -> asynq:{<qname>}:active
 // KEYS[2] -> asynq:{<qname>}:lease
 // KEYS[3] -> asynq:{<qname>}:t:<task_id>
@@ -816,6 +827,37 @@ func (r *RDB) Retry(ctx context.Context, msg *base.TaskMessage, processAt time.T
 	return r.runScript(ctx, op, retryCmd, keys, argv...)
 }
 
+// KEYS[1] -> asynq:{<qname>}:t:<task_id>
+// KEYS[2] -> asynq:{<qname>}:active
+// KEYS[3] -> asynq:{<qname>}:lease
+// KEYS[4] -> asynq:{<qname>}:scheduled
+// -------
+// ARGV[1] -> task ID
+// ARGV[2] -> process_at UNIX timestamp
+var rescheduleCmd = redis.NewScript(`
+if redis.call("LREM", KEYS[2], 0, ARGV[1]) == 0 then
+  return redis.error_reply("NOT FOUND")
+end
+if redis.call("ZREM", KEYS[3], ARGV[1]) == 0 then
+  return redis.error_reply("NOT FOUND")
+end
+redis.call("ZADD", KEYS[4], ARGV[2], ARGV[1])
+redis.call("HSET", KEYS[1], "state", "scheduled")
+return redis.status_reply("OK")`)
+
+// Reschedule moves the task from active to scheduled set to be processed at the given time.
+// Unlike Retry, it leaves the task message as is and does not update the queue stats.
+func (r *RDB) Reschedule(ctx context.Context, msg *base.TaskMessage, processAt time.Time) error {
+	var op errors.Op = "rdb.Reschedule"
+	keys := []string{
+		base.TaskKey(msg.Queue, msg.ID),
+		base.ActiveKey(msg.Queue),
+		base.LeaseKey(msg.Queue),
+		base.ScheduledKey(msg.Queue),
+	}
+	return r.runScript(ctx, op, rescheduleCmd, keys, msg.ID, processAt.Unix())
+}
+
 const (
 	maxArchiveSize           = 10000 // maximum number of tasks in archive
 	archivedExpirationInDays = 90    // number of days before an archived task gets deleted permanently
@@ -1284,12 +1326,14 @@ local res = {}
 local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
 for _, id in ipairs(ids) do
 	local key = ARGV[2] .. id
+	table.insert(res, id)
 	table.insert(res, redis.call("HGET", key, "msg"))
 end
 return res
 `)
 
 // ListLeaseExpired returns a list of task messages with an expired lease from the given queues.
+// A task whose message cannot be decoded is moved to the archive and left out of the list.
 func (r *RDB) ListLeaseExpired(cutoff time.Time, qnames ...string) ([]*base.TaskMessage, error) {
 	var op errors.Op = "rdb.ListLeaseExpired"
 	var msgs []*base.TaskMessage
@@ -1304,10 +1348,13 @@ func (r *RDB) ListLeaseExpired(cutoff time.Time, qnames ...string) ([]*base.Task
 		if err != nil {
 			return nil, errors.E(op, errors.Internal, fmt.Sprintf("cast error: Lua script returned unexpected value: %v", res))
 		}
-		for _, s := range data {
-			msg, err := base.DecodeMessage([]byte(s))
+		for i := 0; i+1 < len(data); i += 2 {
+			msg, err := base.DecodeMessage([]byte(data[i+1]))
 			if err != nil {
-				return nil, errors.E(op, errors.Internal, fmt.Sprintf("cannot decode message: %v", err))
+				if err := r.archiveMalformed(qname, data[i], data[i+1], err); err != nil {
+					return nil, errors.E(op, errors.CanonicalCode(err), err)
+				}
+				continue
 			}
 			msgs = append(msgs, msg)
 		}
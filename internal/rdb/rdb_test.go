@@ -531,6 +531,47 @@ func TestDequeueError(t *testing.T) {
 	}
 }
 
+func TestDequeueArchivesMalformedMessage(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	t1 := h.NewTaskMessageWithQueue("task1", nil, "default")
+	t2 := h.NewTaskMessageWithQueue("task2", nil, "critical")
+	h.SeedPendingQueue(t, r.client, []*base.TaskMessage{t1}, "default")
+	h.SeedPendingQueue(t, r.client, []*base.TaskMessage{t2}, "critical")
+	garbage := "not a valid task message"
+	if err := r.client.HSet(context.Background(), base.TaskKey("default", t1.ID), "msg", garbage).Err(); err != nil {
+		t.Fatalf("could not overwrite task message: %v", err)
+	}
+
+	gotMsg, _, err := r.Dequeue("default", "critical")
+	if err != nil {
+		t.Fatalf("(*RDB).Dequeue() returned error: %v", err)
+	}
+	if diff := cmp.Diff(t2, gotMsg); diff != "" {
+		t.Errorf("(*RDB).Dequeue() returned message %v; want %v; (-want,+got):\n%s", gotMsg, t2, diff)
+	}
+
+	if got := h.GetActiveMessages(t, r.client, "default"); len(got) != 0 {
+		t.Errorf("%q has %d tasks, want 0", base.ActiveKey("default"), len(got))
+	}
+	if got := h.GetLeaseEntries(t, r.client, "default"); len(got) != 0 {
+		t.Errorf("%q has %d entries, want 0", base.LeaseKey("default"), len(got))
+	}
+	archived := h.GetArchivedMessages(t, r.client, "default")
+	if len(archived) != 1 {
+		t.Fatalf("%q has %d tasks, want 1", base.ArchivedKey("default"), len(archived))
+	}
+	if archived[0].ID != t1.ID {
+		t.Errorf("archived task ID = %q, want %q", archived[0].ID, t1.ID)
+	}
+	if string(archived[0].Payload) != garbage {
+		t.Errorf("archived task payload = %q, want %q", archived[0].Payload, garbage)
+	}
+	if !strings.HasPrefix(archived[0].ErrorMsg, "malformed message") {
+		t.Errorf("archived task error message = %q, want prefix %q", archived[0].ErrorMsg, "malformed message")
+	}
+}
+
 func TestDequeueIgnoresPausedQueues(t *testing.T) {
 	r := setup(t)
 	defer r.Close()
@@ -835,6 +876,44 @@ func TestDoneWithMaxCounter(t *testing.T) {
 	}
 }
 
+func TestDoneTwice(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	msg := &base.TaskMessage{
+		ID:       uuid.NewString(),
+		Type:     "foo",
+		Payload:  nil,
+		Timeout:  1800,
+		Deadline: 0,
+		Queue:    "default",
+	}
+
+	z := base.Z{
+		Message: msg,
+		Score:   time.Now().Add(15 * time.Second).Unix(),
+	}
+	h.SeedLease(t, r.client, []base.Z{z}, msg.Queue)
+	h.SeedActiveQueue(t, r.client, []*base.TaskMessage{msg}, msg.Queue)
+
+	ctx := context.Background()
+	if err := r.Done(ctx, msg); err != nil {
+		t.Fatalf("first RDB.Done failed: %v", err)
+	}
+	if err := r.Done(ctx, msg); err == nil {
+		t.Error("second RDB.Done succeeded, want error since the task is no longer active")
+	}
+
+	// Stats should be counted only once.
+	processedKey := base.ProcessedKey(msg.Queue, time.Now())
+	if got := r.client.Get(ctx, processedKey).Val(); got != "1" {
+		t.Errorf("GET %q = %v, want 1", processedKey, got)
+	}
+	processedTotalKey := base.ProcessedTotalKey(msg.Queue)
+	if got := r.client.Get(ctx, processedTotalKey).Val(); got != "1" {
+		t.Errorf("GET %q = %v, want 1", processedTotalKey, got)
+	}
+}
+
 func TestMarkAsComplete(t *testing.T) {
 	r := setup(t)
 	defer r.Close()
@@ -1962,6 +2041,42 @@ func TestRetryWithNonFailureError(t *testing.T) {
 	}
 }
 
+func TestReschedule(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	now := time.Now()
+	t1 := h.NewTaskMessage("send_email", nil)
+	t1.Retried = 3
+	t1.ErrorMsg = "previous failure"
+	t1.LastFailedAt = now.Add(-time.Hour).Unix()
+	h.SeedActiveQueue(t, r.client, []*base.TaskMessage{t1}, t1.Queue)
+	h.SeedLease(t, r.client, []base.Z{{Message: t1, Score: now.Add(10 * time.Second).Unix()}}, t1.Queue)
+	processAt := now.Add(time.Hour)
+
+	if err := r.Reschedule(context.Background(), t1, processAt); err != nil {
+		t.Fatalf("(*RDB).Reschedule(%v, %v) returned error: %v", t1, processAt, err)
+	}
+
+	want := []base.Z{{Message: t1, Score: processAt.Unix()}}
+	if diff := cmp.Diff(want, h.GetScheduledEntries(t, r.client, t1.Queue)); diff != "" {
+		t.Errorf("mismatch found in %q; (-want,+got)\n%s", base.ScheduledKey(t1.Queue), diff)
+	}
+	if got := h.GetActiveMessages(t, r.client, t1.Queue); len(got) != 0 {
+		t.Errorf("%q has %d tasks, want 0", base.ActiveKey(t1.Queue), len(got))
+	}
+	if got := h.GetLeaseEntries(t, r.client, t1.Queue); len(got) != 0 {
+		t.Errorf("%q has %d entries, want 0", base.LeaseKey(t1.Queue), len(got))
+	}
+	if got := r.client.HGet(context.Background(), base.TaskKey(t1.Queue, t1.ID), "state").Val(); got != "scheduled" {
+		t.Errorf("task state = %q, want %q", got, "scheduled")
+	}
+	for _, key := range []string{base.ProcessedKey(t1.Queue, now), base.FailedKey(t1.Queue, now)} {
+		if r.client.Exists(context.Background(), key).Val() != 0 {
+			t.Errorf("%q exists, want rescheduling not to update stats", key)
+		}
+	}
+}
+
 func TestArchive(t *testing.T) {
 	r := setup(t)
 	defer r.Close()
@@ -2643,6 +2758,34 @@ func TestListLeaseExpired(t *testing.T) {
 	}
 }
 
+func TestListLeaseExpiredArchivesMalformedMessage(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	t1 := h.NewTaskMessageWithQueue("task1", nil, "default")
+	t2 := h.NewTaskMessageWithQueue("task2", nil, "default")
+	now := time.Now()
+	h.SeedActiveQueue(t, r.client, []*base.TaskMessage{t1, t2}, "default")
+	h.SeedLease(t, r.client, []base.Z{
+		{Message: t1, Score: now.Add(-10 * time.Second).Unix()},
+		{Message: t2, Score: now.Add(-10 * time.Second).Unix()},
+	}, "default")
+	if err := r.client.HSet(context.Background(), base.TaskKey("default", t1.ID), "msg", "garbage").Err(); err != nil {
+		t.Fatalf("could not overwrite task message: %v", err)
+	}
+
+	got, err := r.ListLeaseExpired(now, "default")
+	if err != nil {
+		t.Fatalf("ListLeaseExpired(%v) returned error: %v", now, err)
+	}
+	if diff := cmp.Diff([]*base.TaskMessage{t2}, got); diff != "" {
+		t.Errorf("ListLeaseExpired(%v) returned %v; (-want,+got)\n%s", now, got, diff)
+	}
+	archived := h.GetArchivedMessages(t, r.client, "default")
+	if len(archived) != 1 || archived[0].ID != t1.ID {
+		t.Errorf("%q contains %v, want the malformed task %q", base.ArchivedKey("default"), archived, t1.ID)
+	}
+}
+
 func TestExtendLease(t *testing.T) {
 	r := setup(t)
 	defer r.Close()
@@ -127,6 +127,15 @@ func (tb *TestBroker) Retry(ctx context.Context, msg *base.TaskMessage, processA
 	return tb.real.Retry(ctx, msg, processAt, errMsg, isFailure)
 }
 
+func (tb *TestBroker) Reschedule(ctx context.Context, msg *base.TaskMessage, processAt time.Time) error {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.sleeping {
+		return errRedisDown
+	}
+	return tb.real.Reschedule(ctx, msg, processAt)
+}
+
 func (tb *TestBroker) Archive(ctx context.Context, msg *base.TaskMessage, errMsg string) error {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
@@ -72,6 +72,7 @@ func (j *janitor) start(wg *sync.WaitGroup) {
 }
 
 func (j *janitor) exec() {
+	defer recoverPanic(j.logger, "janitor")
 	for _, qname := range j.queues {
 		if err := j.broker.DeleteExpiredCompletedTasks(qname); err != nil {
 			j.logger.Errorf("Failed to delete expired completed tasks from queue %q: %v",
@@ -37,22 +37,52 @@ type processor struct {
 	// orderedQueues is set only in strict-priority mode.
 	orderedQueues []string
 
+	// rateLimiters maps queue names to the limiter used to pace dequeues from the queue.
+	// Queues without a limiter are not rate limited.
+	rateLimiters map[string]*rate.Limiter
+
 	retryDelayFunc RetryDelayFunc
 	isFailureFunc  func(error) bool
 
 	errHandler ErrorHandler
 
+	panicBehavior PanicBehavior
+
 	shutdownTimeout time.Duration
 
+	// interval to wait before checking again when all queues are empty.
+	taskCheckInterval time.Duration
+
+	// handler calls taking longer than this are logged; zero disables the logging.
+	slowTaskThreshold time.Duration
+
 	// channel via which to send sync requests to syncer.
 	syncRequestCh chan<- *syncRequest
 
 	// rate limiter to prevent spamming logs with a bunch of errors.
 	errLogLimiter *rate.Limiter
 
-	// sema is a counting semaphore to ensure the number of active workers
-	// does not exceed the limit.
-	sema chan struct{}
+	// dequeueErrCount is the number of consecutive failed attempts to dequeue
+	// a task. It should be accessed only by the "processor" goroutine.
+	dequeueErrCount int
+
+	// execPanicCount is the number of consecutive calls to exec that panicked.
+	// It should be accessed only by the "processor" goroutine.
+	execPanicCount int
+
+	// mu guards concurrency, numActive, tokenCh and numWorkers.
+	//
+	// The first three form a counting semaphore to ensure the number of
+	// active workers does not exceed the limit, which can be changed at runtime.
+	mu          sync.Mutex
+	concurrency int
+	numActive   int
+	// tokenCh is closed and replaced whenever a token is released or the
+	// concurrency changes, to wake up goroutines waiting for a token.
+	tokenCh chan struct{}
+	// numWorkers is the number of worker goroutines processing a task.
+	// Unlike numActive, it does not count the token held while dequeueing.
+	numWorkers int
 
 	// channel to communicate back to the long running "processor" goroutine.
 	// once is used to send value to the channel only once.
@@ -73,20 +103,24 @@ type processor struct {
 }
 
 type processorParams struct {
-	logger          *log.Logger
-	broker          base.Broker
-	baseCtxFn       func() context.Context
-	retryDelayFunc  RetryDelayFunc
-	isFailureFunc   func(error) bool
-	syncCh          chan<- *syncRequest
-	cancelations    *base.Cancelations
-	concurrency     int
-	queues          map[string]int
-	strictPriority  bool
-	errHandler      ErrorHandler
-	shutdownTimeout time.Duration
-	starting        chan<- *workerInfo
-	finished        chan<- *base.TaskMessage
+	logger            *log.Logger
+	broker            base.Broker
+	baseCtxFn         func() context.Context
+	retryDelayFunc    RetryDelayFunc
+	isFailureFunc     func(error) bool
+	syncCh            chan<- *syncRequest
+	cancelations      *base.Cancelations
+	concurrency       int
+	queues            map[string]int
+	strictPriority    bool
+	rateLimiters      map[string]*rate.Limiter
+	errHandler        ErrorHandler
+	panicBehavior     PanicBehavior
+	shutdownTimeout   time.Duration
+	taskCheckInterval time.Duration
+	slowTaskThreshold time.Duration
+	starting          chan<- *workerInfo
+	finished          chan<- *base.TaskMessage
 }
 
 // newProcessor constructs a new processor.
@@ -96,27 +130,36 @@ func newProcessor(params processorParams) *processor {
 	if params.strictPriority {
 		orderedQueues = sortByPriority(queues)
 	}
+	taskCheckInterval := params.taskCheckInterval
+	if taskCheckInterval <= 0 {
+		taskCheckInterval = defaultTaskCheckInterval
+	}
 	return &processor{
-		logger:          params.logger,
-		broker:          params.broker,
-		baseCtxFn:       params.baseCtxFn,
-		clock:           timeutil.NewRealClock(),
-		queueConfig:     queues,
-		orderedQueues:   orderedQueues,
-		retryDelayFunc:  params.retryDelayFunc,
-		isFailureFunc:   params.isFailureFunc,
-		syncRequestCh:   params.syncCh,
-		cancelations:    params.cancelations,
-		errLogLimiter:   rate.NewLimiter(rate.Every(3*time.Second), 1),
-		sema:            make(chan struct{}, params.concurrency),
-		done:            make(chan struct{}),
-		quit:            make(chan struct{}),
-		abort:           make(chan struct{}),
-		errHandler:      params.errHandler,
-		handler:         HandlerFunc(func(ctx context.Context, t *Task) error { return fmt.Errorf("handler not set") }),
-		shutdownTimeout: params.shutdownTimeout,
-		starting:        params.starting,
-		finished:        params.finished,
+		logger:            params.logger,
+		broker:            params.broker,
+		baseCtxFn:         params.baseCtxFn,
+		clock:             timeutil.NewRealClock(),
+		queueConfig:       queues,
+		orderedQueues:     orderedQueues,
+		rateLimiters:      params.rateLimiters,
+		retryDelayFunc:    params.retryDelayFunc,
+		isFailureFunc:     params.isFailureFunc,
+		syncRequestCh:     params.syncCh,
+		cancelations:      params.cancelations,
+		errLogLimiter:     rate.NewLimiter(rate.Every(3*time.Second), 1),
+		concurrency:       params.concurrency,
+		tokenCh:           make(chan struct{}),
+		done:              make(chan struct{}),
+		quit:              make(chan struct{}),
+		abort:             make(chan struct{}),
+		errHandler:        params.errHandler,
+		panicBehavior:     params.panicBehavior,
+		handler:           HandlerFunc(func(ctx context.Context, t *Task) error { return fmt.Errorf("handler not set") }),
+		shutdownTimeout:   params.shutdownTimeout,
+		taskCheckInterval: taskCheckInterval,
+		slowTaskThreshold: params.slowTaskThreshold,
+		starting:          params.starting,
+		finished:          params.finished,
 	}
 }
 
@@ -125,7 +168,7 @@ func newProcessor(params processorParams) *processor {
 func (p *processor) stop() {
 	p.once.Do(func() {
 		p.logger.Debug("Processor shutting down...")
-		// Unblock if processor is waiting for sema token.
+		// Unblock if processor is waiting for a token.
 		close(p.quit)
 		// Signal the processor goroutine to stop processing tasks
 		// from the queue.
@@ -141,12 +184,77 @@ func (p *processor) shutdown() {
 
 	p.logger.Info("Waiting for all workers to finish...")
 	// block until all workers have released the token
-	for i := 0; i < cap(p.sema); i++ {
-		p.sema <- struct{}{}
+	for {
+		p.mu.Lock()
+		if p.numActive == 0 {
+			p.mu.Unlock()
+			break
+		}
+		ch := p.tokenCh
+		p.mu.Unlock()
+		<-ch
 	}
 	p.logger.Info("All workers have finished")
 }
 
+// acquireToken blocks until the number of active workers is below the
+// concurrency limit and takes a token, or until the processor starts shutting down.
+// It reports whether a token was acquired.
+func (p *processor) acquireToken() bool {
+	for {
+		select {
+		case <-p.quit:
+			return false
+		default:
+		}
+		p.mu.Lock()
+		if p.numActive < p.concurrency {
+			p.numActive++
+			p.mu.Unlock()
+			return true
+		}
+		ch := p.tokenCh
+		p.mu.Unlock()
+		select {
+		case <-p.quit:
+			return false
+		case <-ch:
+		}
+	}
+}
+
+// releaseToken releases a token acquired by acquireToken.
+func (p *processor) releaseToken() {
+	p.mu.Lock()
+	p.numActive--
+	p.notifyTokenWaiters()
+	p.mu.Unlock()
+}
+
+// setConcurrency changes the maximum number of active workers.
+// Lowering the limit does not stop active workers; new tasks are not
+// processed until enough workers finish to get below the new limit.
+func (p *processor) setConcurrency(n int) {
+	p.mu.Lock()
+	p.concurrency = n
+	p.notifyTokenWaiters()
+	p.mu.Unlock()
+}
+
+// activeWorkers returns the number of workers currently processing a task.
+func (p *processor) activeWorkers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.numWorkers
+}
+
+// notifyTokenWaiters wakes up all goroutines waiting on tokenCh.
+// p.mu must be held by the caller.
+func (p *processor) notifyTokenWaiters() {
+	close(p.tokenCh)
+	p.tokenCh = make(chan struct{})
+}
+
 func (p *processor) start(wg *sync.WaitGroup) {
 	wg.Add(1)
 	go func() {
@@ -166,92 +274,163 @@ func (p *processor) start(wg *sync.WaitGroup) {
 // exec pulls a task out of the queue and starts a worker goroutine to
 // process the task.
 func (p *processor) exec() {
-	select {
-	case <-p.quit:
+	defer p.recoverExecPanic()
+	if !p.acquireToken() {
+		return
+	}
+	started := false
+	defer func() {
+		// Give back the token unless a worker goroutine took it over.
+		if !started {
+			p.releaseToken()
+		}
+	}()
+	qnames, reservations, reservedAt, delay := p.reserveQueues(p.queues())
+	if len(qnames) == 0 {
+		p.logger.Debug("All queues are rate limited")
+		// Wait until the first rate limited queue allows another task,
+		// but no longer than we would wait for empty queues.
+		if delay > p.taskCheckInterval {
+			delay = p.taskCheckInterval
+		}
+		select {
+		case <-p.quit:
+		case <-time.After(delay):
+		}
+		return
+	}
+	msg, leaseExpirationTime, err := p.broker.Dequeue(qnames...)
+	for qname, r := range reservations {
+		// Give back the reserved tokens of the queues the task did not come from.
+		// Note: Cancel would use the current time, which is past the time the
+		// reservation was granted, and then it restores nothing.
+		if err != nil || qname != msg.Queue {
+			r.CancelAt(reservedAt)
+		}
+	}
+	switch {
+	case errors.Is(err, errors.ErrNoProcessableTask):
+		p.dequeueErrCount = 0
+		p.logger.Debug("All queues are empty")
+		// Queues are empty, this is a normal behavior.
+		// Sleep to avoid slamming redis and let scheduler move tasks into queues.
+		// Note: We are not using blocking pop operation and polling queues instead.
+		// This adds significant load to redis.
+		select {
+		case <-p.quit:
+		case <-time.After(p.taskCheckInterval):
+		}
+		return
+	case errors.CanonicalCode(err) == errors.Internal:
+		// Redis returned an unexpected value (e.g. from an incompatible version of the scripts).
+		// Task messages that cannot be decoded are archived by Dequeue and do not end up here.
+		// Redis itself is reachable, so there is no need to back off.
+		p.dequeueErrCount = 0
+		if p.errLogLimiter.Allow() {
+			p.logger.Errorf("Dequeue error: %v", err)
+		}
 		return
-	case p.sema <- struct{}{}: // acquire token
-		qnames := p.queues()
-		msg, leaseExpirationTime, err := p.broker.Dequeue(qnames...)
-		switch {
-		case errors.Is(err, errors.ErrNoProcessableTask):
-			p.logger.Debug("All queues are empty")
-			// Queues are empty, this is a normal behavior.
-			// Sleep to avoid slamming redis and let scheduler move tasks into queues.
-			// Note: We are not using blocking pop operation and polling queues instead.
-			// This adds significant load to redis.
-			time.Sleep(time.Second)
-			<-p.sema // release token
+	case err != nil:
+		// Most likely redis is unreachable. Back off to avoid spinning
+		// while waiting for the connection to recover.
+		p.dequeueErrCount++
+		d := dequeueErrBackoff(p.dequeueErrCount)
+		if p.errLogLimiter.Allow() {
+			p.logger.Errorf("Dequeue error: %v; retrying in %v", err, d)
+		}
+		select {
+		case <-p.quit:
+		case <-time.After(d):
+		}
+		return
+	}
+	p.dequeueErrCount = 0
+
+	lease := base.NewLease(leaseExpirationTime)
+	deadline := p.computeDeadline(msg)
+	p.starting <- &workerInfo{msg, time.Now(), deadline, lease}
+	p.mu.Lock()
+	p.numWorkers++
+	p.mu.Unlock()
+	started = true
+	go func() {
+		// Recover from a panic in user supplied funcs called after the handler returns
+		// (e.g. RetryDelayFunc, IsFailure). The task is left active, and the recoverer
+		// picks it up once its lease expires.
+		defer recoverPanic(p.logger, "processor")
+		defer func() {
+			p.finished <- msg
+			p.mu.Lock()
+			p.numWorkers--
+			p.mu.Unlock()
+			p.releaseToken()
+		}()
+
+		ctx, cancel := asynqcontext.New(p.baseCtxFn(), msg, deadline)
+		p.cancelations.Add(msg.ID, cancel)
+		defer func() {
+			cancel()
+			p.cancelations.Delete(msg.ID)
+		}()
+
+		// a message without a type cannot be routed to any handler,
+		// and retrying it would not help.
+		if strings.TrimSpace(msg.Type) == "" {
+			p.logger.Warnf("Archiving task id=%s with empty type", msg.ID)
+			p.archive(lease, msg, errEmptyTaskType)
 			return
-		case err != nil:
-			if p.errLogLimiter.Allow() {
-				p.logger.Errorf("Dequeue error: %v", err)
-			}
-			<-p.sema // release token
+		}
+
+		// check context before starting a worker goroutine.
+		select {
+		case <-ctx.Done():
+			// already canceled (e.g. deadline exceeded).
+			p.handleFailedMessage(ctx, lease, msg, ctx.Err())
 			return
+		default:
 		}
 
-		lease := base.NewLease(leaseExpirationTime)
-		deadline := p.computeDeadline(msg)
-		p.starting <- &workerInfo{msg, time.Now(), deadline, lease}
+		resCh := make(chan error, 1)
 		go func() {
-			defer func() {
-				p.finished <- msg
-				<-p.sema // release token
-			}()
-
-			ctx, cancel := asynqcontext.New(p.baseCtxFn(), msg, deadline)
-			p.cancelations.Add(msg.ID, cancel)
-			defer func() {
-				cancel()
-				p.cancelations.Delete(msg.ID)
-			}()
-
-			// check context before starting a worker goroutine.
-			select {
-			case <-ctx.Done():
-				// already canceled (e.g. deadline exceeded).
-				p.handleFailedMessage(ctx, lease, msg, ctx.Err())
-				return
-			default:
+			task := newTask(
+				msg.Type,
+				msg.Payload,
+				&ResultWriter{
+					id:     msg.ID,
+					qname:  msg.Queue,
+					broker: p.broker,
+					ctx:    ctx,
+				},
+			)
+			start := time.Now()
+			err := p.perform(ctx, task)
+			if d := time.Since(start); p.slowTaskThreshold > 0 && d > p.slowTaskThreshold {
+				p.logger.Warnf("Slow task: id=%s type=%q took %v", msg.ID, msg.Type, d)
 			}
+			resCh <- err
+		}()
 
-			resCh := make(chan error, 1)
-			go func() {
-				task := newTask(
-					msg.Type,
-					msg.Payload,
-					&ResultWriter{
-						id:     msg.ID,
-						qname:  msg.Queue,
-						broker: p.broker,
-						ctx:    ctx,
-					},
-				)
-				resCh <- p.perform(ctx, task)
-			}()
-
-			select {
-			case <-p.abort:
-				// time is up, push the message back to queue and quit this worker goroutine.
-				p.logger.Warnf("Quitting worker. task id=%s", msg.ID)
-				p.requeue(lease, msg)
-				return
-			case <-lease.Done():
-				cancel()
-				p.handleFailedMessage(ctx, lease, msg, ErrLeaseExpired)
-				return
-			case <-ctx.Done():
-				p.handleFailedMessage(ctx, lease, msg, ctx.Err())
+		select {
+		case <-p.abort:
+			// time is up, push the message back to queue and quit this worker goroutine.
+			p.logger.Warnf("Quitting worker. task id=%s", msg.ID)
+			p.requeue(lease, msg)
+			return
+		case <-lease.Done():
+			cancel()
+			p.handleFailedMessage(ctx, lease, msg, ErrLeaseExpired)
+			return
+		case <-ctx.Done():
+			p.handleFailedMessage(ctx, lease, msg, ctx.Err())
+			return
+		case resErr := <-resCh:
+			if resErr != nil {
+				p.handleFailedMessage(ctx, lease, msg, resErr)
 				return
-			case resErr := <-resCh:
-				if resErr != nil {
-					p.handleFailedMessage(ctx, lease, msg, resErr)
-					return
-				}
-				p.handleSucceededMessage(lease, msg)
 			}
-		}()
-	}
+			p.handleSucceededMessage(lease, msg)
+		}
+	}()
 }
 
 func (p *processor) requeue(l *base.Lease, msg *base.TaskMessage) {
@@ -259,7 +438,8 @@ func (p *processor) requeue(l *base.Lease, msg *base.TaskMessage) {
 		// If lease is not valid, do not write to redis; Let recoverer take care of it.
 		return
 	}
-	ctx, _ := context.WithDeadline(context.Background(), l.Deadline())
+	ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+	defer cancel()
 	err := p.broker.Requeue(ctx, msg)
 	if err != nil {
 		p.logger.Errorf("Could not push task id=%s back to queue: %v", msg.ID, err)
@@ -281,7 +461,8 @@ func (p *processor) markAsComplete(l *base.Lease, msg *base.TaskMessage) {
 		// If lease is not valid, do not write to redis; Let recoverer take care of it.
 		return
 	}
-	ctx, _ := context.WithDeadline(context.Background(), l.Deadline())
+	ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+	defer cancel()
 	err := p.broker.MarkAsComplete(ctx, msg)
 	if err != nil {
 		errMsg := fmt.Sprintf("Could not move task id=%s type=%q from %q to %q:  %+v",
@@ -289,6 +470,8 @@ func (p *processor) markAsComplete(l *base.Lease, msg *base.TaskMessage) {
 		p.logger.Warnf("%s; Will retry syncing", errMsg)
 		p.syncRequestCh <- &syncRequest{
 			fn: func() error {
+				ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+				defer cancel()
 				return p.broker.MarkAsComplete(ctx, msg)
 			},
 			errMsg:   errMsg,
@@ -302,13 +485,16 @@ func (p *processor) markAsDone(l *base.Lease, msg *base.TaskMessage) {
 		// If lease is not valid, do not write to redis; Let recoverer take care of it.
 		return
 	}
-	ctx, _ := context.WithDeadline(context.Background(), l.Deadline())
+	ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+	defer cancel()
 	err := p.broker.Done(ctx, msg)
 	if err != nil {
 		errMsg := fmt.Sprintf("Could not remove task id=%s type=%q from %q err: %+v", msg.ID, msg.Type, base.ActiveKey(msg.Queue), err)
 		p.logger.Warnf("%s; Will retry syncing", errMsg)
 		p.syncRequestCh <- &syncRequest{
 			fn: func() error {
+				ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+				defer cancel()
 				return p.broker.Done(ctx, msg)
 			},
 			errMsg:   errMsg,
@@ -317,19 +503,109 @@ func (p *processor) markAsDone(l *base.Lease, msg *base.TaskMessage) {
 	}
 }
 
+// errEmptyTaskType is recorded for a task message whose type is empty.
+var errEmptyTaskType = errors.New("task typename cannot be empty")
+
 // SkipRetry is used as a return value from Handler.ProcessTask to indicate that
 // the task should not be retried and should be archived instead.
 var SkipRetry = errors.New("skip retry for the task")
 
+// RescheduleAt returns an error which, when returned from Handler.ProcessTask,
+// reschedules the task to be processed again at the given time.
+//
+// Rescheduling is not treated as a failure: the task is moved to the scheduled state
+// as is, so its retry count and last error are kept. The ErrorHandler is not called,
+// and the task does not count towards the processed or failed stats of the queue.
+func RescheduleAt(t time.Time) error {
+	return &rescheduleError{at: t}
+}
+
+// RescheduleIn returns an error which, when returned from Handler.ProcessTask,
+// reschedules the task to be processed again after the given duration.
+//
+// See RescheduleAt for details.
+func RescheduleIn(d time.Duration) error {
+	return &rescheduleError{in: d}
+}
+
+// rescheduleError is the error returned by RescheduleAt and RescheduleIn.
+type rescheduleError struct {
+	at time.Time     // set by RescheduleAt
+	in time.Duration // set by RescheduleIn
+}
+
+func (e *rescheduleError) Error() string {
+	if !e.at.IsZero() {
+		return fmt.Sprintf("task rescheduled at %s", e.at.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("task rescheduled in %v", e.in)
+}
+
+// processAt returns the time to process the task given the current time.
+func (e *rescheduleError) processAt(now time.Time) time.Time {
+	if !e.at.IsZero() {
+		return e.at
+	}
+	return now.Add(e.in)
+}
+
+// PanicError is the error returned for a task whose handler panicked.
+//
+// ErrorHandler and IsFailure receive a *PanicError in that case, and
+// errors.As can be used to access the stack trace captured at the time of the panic.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// File and Line locate the code that triggered the panic.
+	// File is empty if the location could not be determined.
+	File string
+	Line int
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+// Error returns a concise description of the panic.
+// The stack trace is not included; use the Stack field to access it.
+func (e *PanicError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("panic: %v", e.Value)
+	}
+	return fmt.Sprintf("panic [%s:%d]: %v", e.File, e.Line, e.Value)
+}
+
 func (p *processor) handleFailedMessage(ctx context.Context, l *base.Lease, msg *base.TaskMessage, err error) {
+	var rerr *rescheduleError
+	if errors.As(err, &rerr) {
+		p.reschedule(l, msg, rerr.processAt(time.Now()))
+		return
+	}
 	if p.errHandler != nil {
-		p.errHandler.HandleError(ctx, NewTask(msg.Type, msg.Payload), err)
+		p.handleError(ctx, msg, err)
+	}
+	if msg.Deadline != 0 && !p.clock.Now().Before(time.Unix(msg.Deadline, 0)) {
+		// The task's deadline has passed, so any retry would fail right away.
+		p.logger.Warnf("Deadline exceeded for task id=%s, archiving the task", msg.ID)
+		p.archive(l, msg, err)
+		return
+	}
+	var perr *PanicError
+	if p.panicBehavior == PanicArchive && errors.As(err, &perr) {
+		p.logger.Warnf("Handler panicked for task id=%s, archiving the task", msg.ID)
+		p.archive(l, msg, err)
+		return
 	}
 	if !p.isFailureFunc(err) {
 		// retry the task without marking it as failed
 		p.retry(l, msg, err, false /*isFailure*/)
 		return
 	}
+	if errors.Is(err, ErrHandlerNotFound) {
+		p.logger.Warnf("No handler found for task id=%s type=%q, archiving the task", msg.ID, msg.Type)
+		p.archive(l, msg, err)
+		return
+	}
 	if msg.Retried >= msg.Retry || errors.Is(err, SkipRetry) {
 		p.logger.Warnf("Retry exhausted for task id=%s", msg.ID)
 		p.archive(l, msg, err)
@@ -338,12 +614,26 @@ func (p *processor) handleFailedMessage(ctx context.Context, l *base.Lease, msg
 	}
 }
 
+// handleError calls the ErrorHandler with the given error.
+// A panic in the ErrorHandler is recovered and logged, so that the task
+// is still retried or archived afterwards.
+func (p *processor) handleError(ctx context.Context, msg *base.TaskMessage, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			p.logger.Errorf("recovering from panic in ErrorHandler for task id=%s: %v. See the stack trace below for details:\n%s",
+				msg.ID, x, string(debug.Stack()))
+		}
+	}()
+	p.errHandler.HandleError(ctx, NewTask(msg.Type, msg.Payload), err)
+}
+
 func (p *processor) retry(l *base.Lease, msg *base.TaskMessage, e error, isFailure bool) {
 	if !l.IsValid() {
 		// If lease is not valid, do not write to redis; Let recoverer take care of it.
 		return
 	}
-	ctx, _ := context.WithDeadline(context.Background(), l.Deadline())
+	ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+	defer cancel()
 	d := p.retryDelayFunc(msg.Retried, e, NewTask(msg.Type, msg.Payload))
 	retryAt := time.Now().Add(d)
 	err := p.broker.Retry(ctx, msg, retryAt, e.Error(), isFailure)
@@ -352,6 +642,8 @@ func (p *processor) retry(l *base.Lease, msg *base.TaskMessage, e error, isFailu
 		p.logger.Warnf("%s; Will retry syncing", errMsg)
 		p.syncRequestCh <- &syncRequest{
 			fn: func() error {
+				ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+				defer cancel()
 				return p.broker.Retry(ctx, msg, retryAt, e.Error(), isFailure)
 			},
 			errMsg:   errMsg,
@@ -360,18 +652,45 @@ func (p *processor) retry(l *base.Lease, msg *base.TaskMessage, e error, isFailu
 	}
 }
 
+// reschedule moves the task to the scheduled state to be processed again at the given time.
+func (p *processor) reschedule(l *base.Lease, msg *base.TaskMessage, processAt time.Time) {
+	if !l.IsValid() {
+		// If lease is not valid, do not write to redis; Let recoverer take care of it.
+		return
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+	defer cancel()
+	err := p.broker.Reschedule(ctx, msg, processAt)
+	if err != nil {
+		errMsg := fmt.Sprintf("Could not move task id=%s from %q to %q", msg.ID, base.ActiveKey(msg.Queue), base.ScheduledKey(msg.Queue))
+		p.logger.Warnf("%s; Will retry syncing", errMsg)
+		p.syncRequestCh <- &syncRequest{
+			fn: func() error {
+				ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+				defer cancel()
+				return p.broker.Reschedule(ctx, msg, processAt)
+			},
+			errMsg:   errMsg,
+			deadline: l.Deadline(),
+		}
+	}
+}
+
 func (p *processor) archive(l *base.Lease, msg *base.TaskMessage, e error) {
 	if !l.IsValid() {
 		// If lease is not valid, do not write to redis; Let recoverer take care of it.
 		return
 	}
-	ctx, _ := context.WithDeadline(context.Background(), l.Deadline())
+	ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+	defer cancel()
 	err := p.broker.Archive(ctx, msg, e.Error())
 	if err != nil {
 		errMsg := fmt.Sprintf("Could not move task id=%s from %q to %q", msg.ID, base.ActiveKey(msg.Queue), base.ArchivedKey(msg.Queue))
 		p.logger.Warnf("%s; Will retry syncing", errMsg)
 		p.syncRequestCh <- &syncRequest{
 			fn: func() error {
+				ctx, cancel := context.WithDeadline(context.Background(), l.Deadline())
+				defer cancel()
 				return p.broker.Archive(ctx, msg, e.Error())
 			},
 			errMsg:   errMsg,
@@ -407,13 +726,66 @@ func (p *processor) queues() []string {
 	return uniq(names, len(p.queueConfig))
 }
 
+const (
+	// minDequeueErrBackoff and maxDequeueErrBackoff bound the delay before
+	// the processor tries to dequeue again after an error.
+	minDequeueErrBackoff = 100 * time.Millisecond
+	maxDequeueErrBackoff = 5 * time.Second
+)
+
+// dequeueErrBackoff returns the delay before the next dequeue after n consecutive errors.
+// The delay doubles with each error, up to maxDequeueErrBackoff.
+func dequeueErrBackoff(n int) time.Duration {
+	d := minDequeueErrBackoff
+	for i := 1; i < n && d < maxDequeueErrBackoff; i++ {
+		d *= 2
+	}
+	if d > maxDequeueErrBackoff {
+		d = maxDequeueErrBackoff
+	}
+	return d
+}
+
+// reserveQueues reserves a rate limit token for each rate limited queue in qnames
+// and returns the queues which can be processed now, in the same order.
+// Queues whose limit is used up are excluded, and delay is the duration until
+// the first of them allows another task.
+// The reservations are made at reservedAt, which should be passed to CancelAt
+// to give back a token.
+func (p *processor) reserveQueues(qnames []string) (allowed []string, reservations map[string]*rate.Reservation, reservedAt time.Time, delay time.Duration) {
+	if len(p.rateLimiters) == 0 {
+		return qnames, nil, time.Time{}, 0
+	}
+	reservedAt = time.Now()
+	reservations = make(map[string]*rate.Reservation)
+	for _, qname := range qnames {
+		lim, ok := p.rateLimiters[qname]
+		if !ok {
+			allowed = append(allowed, qname)
+			continue
+		}
+		r := lim.ReserveN(reservedAt, 1)
+		if d := r.DelayFrom(reservedAt); d > 0 {
+			r.CancelAt(reservedAt)
+			if delay == 0 || d < delay {
+				delay = d
+			}
+			continue
+		}
+		reservations[qname] = r
+		allowed = append(allowed, qname)
+	}
+	return allowed, reservations, reservedAt, delay
+}
+
 // perform calls the handler with the given task.
 // If the call returns without panic, it simply returns the value,
 // otherwise, it recovers from panic and returns an error.
 func (p *processor) perform(ctx context.Context, task *Task) (err error) {
 	defer func() {
 		if x := recover(); x != nil {
-			p.logger.Errorf("recovering from panic. See the stack trace below for details:\n%s", string(debug.Stack()))
+			stack := debug.Stack()
+			p.logger.Errorf("recovering from panic. See the stack trace below for details:\n%s", string(stack))
 			_, file, line, ok := runtime.Caller(1) // skip the first frame (panic itself)
 			if ok && strings.Contains(file, "runtime/") {
 				// The panic came from the runtime, most likely due to incorrect
@@ -421,17 +793,47 @@ func (p *processor) perform(ctx context.Context, task *Task) (err error) {
 				_, file, line, ok = runtime.Caller(2)
 			}
 
+			perr := &PanicError{Value: x, Stack: stack}
 			// Include the file and line number info in the error, if runtime.Caller returned ok.
 			if ok {
-				err = fmt.Errorf("panic [%s:%d]: %v", file, line, x)
-			} else {
-				err = fmt.Errorf("panic: %v", x)
+				perr.File, perr.Line = file, line
 			}
+			err = perr
 		}
 	}()
 	return p.handler.ProcessTask(ctx, task)
 }
 
+// recoverExecPanic recovers from a panic in exec and logs it with the stack trace.
+// Since exec is called in a tight loop, it backs off before returning if exec
+// keeps panicking, to avoid burning CPU and flooding logs.
+// It must be called directly with defer.
+func (p *processor) recoverExecPanic() {
+	x := recover()
+	if x == nil {
+		p.execPanicCount = 0
+		return
+	}
+	p.execPanicCount++
+	d := dequeueErrBackoff(p.execPanicCount)
+	if p.errLogLimiter.Allow() {
+		p.logger.Errorf("recovered from panic in processor: %v; retrying in %v\n%s", x, d, debug.Stack())
+	}
+	select {
+	case <-p.quit:
+	case <-time.After(d):
+	}
+}
+
+// recoverPanic recovers from a panic in one iteration of a background goroutine's loop
+// and logs it with the stack trace, so that the goroutine keeps running on the next iteration.
+// It must be called directly with defer.
+func recoverPanic(logger *log.Logger, component string) {
+	if x := recover(); x != nil {
+		logger.Errorf("recovered from panic in %s: %v\n%s", component, x, debug.Stack())
+	}
+}
+
 // uniq dedupes elements and returns a slice of unique names of length l.
 // Order of the output slice is based on the input list.
 func uniq(names []string, l int) []string {
@@ -8,7 +8,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -21,6 +23,7 @@ import (
 	"github.com/hibiken/asynq/internal/rdb"
 	h "github.com/hibiken/asynq/internal/testutil"
 	"github.com/hibiken/asynq/internal/timeutil"
+	"golang.org/x/time/rate"
 )
 
 var taskCmpOpts = []cmp.Option{
@@ -409,6 +412,238 @@ func TestProcessorRetry(t *testing.T) {
 	}
 }
 
+func TestProcessorRetryWithPanickingErrorHandler(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	m := h.NewTaskMessage("send_email", nil)
+	h.SeedPendingQueue(t, r, []*base.TaskMessage{m}, base.DefaultQueueName)
+
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+		return fmt.Errorf("something went wrong")
+	})
+	p := newProcessorForTest(t, rdbClient, handler)
+	p.errHandler = ErrorHandlerFunc(func(ctx context.Context, t *Task, err error) {
+		panic("error handler panicked")
+	})
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(2 * time.Second)
+	p.shutdown()
+
+	if got := h.GetRetryMessages(t, r, base.DefaultQueueName); len(got) != 1 || got[0].ID != m.ID {
+		t.Errorf("%q has %v, want the task to be retried after ErrorHandler panicked", base.RetryKey(base.DefaultQueueName), got)
+	}
+	if l := r.LLen(context.Background(), base.ActiveKey(base.DefaultQueueName)).Val(); l != 0 {
+		t.Errorf("%q has %d tasks, want 0", base.ActiveKey(base.DefaultQueueName), l)
+	}
+}
+
+func TestProcessorPanicBehavior(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	tests := []struct {
+		desc          string
+		panicBehavior PanicBehavior
+		wantRetry     int // number of tasks in retry state at the end
+		wantArchived  int // number of tasks in archived state at the end
+	}{
+		{"PanicRetry should retry the task", PanicRetry, 1, 0},
+		{"PanicArchive should archive the task", PanicArchive, 0, 1},
+	}
+
+	for _, tc := range tests {
+		h.FlushDB(t, r)
+		h.SeedPendingQueue(t, r, []*base.TaskMessage{h.NewTaskMessage("send_email", nil)}, base.DefaultQueueName)
+
+		var (
+			mu      sync.Mutex // guards gotErrs
+			gotErrs []error
+		)
+		handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+			panic("something went terribly wrong")
+		})
+		p := newProcessorForTest(t, rdbClient, handler)
+		p.panicBehavior = tc.panicBehavior
+		p.errHandler = ErrorHandlerFunc(func(ctx context.Context, t *Task, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			gotErrs = append(gotErrs, err)
+		})
+
+		p.start(&sync.WaitGroup{})
+		time.Sleep(2 * time.Second)
+		p.shutdown()
+
+		if got := len(h.GetRetryMessages(t, r, base.DefaultQueueName)); got != tc.wantRetry {
+			t.Errorf("%s: %q has %d tasks, want %d", tc.desc, base.RetryKey(base.DefaultQueueName), got, tc.wantRetry)
+		}
+		if got := len(h.GetArchivedMessages(t, r, base.DefaultQueueName)); got != tc.wantArchived {
+			t.Errorf("%s: %q has %d tasks, want %d", tc.desc, base.ArchivedKey(base.DefaultQueueName), got, tc.wantArchived)
+		}
+		mu.Lock()
+		var perr *PanicError
+		if len(gotErrs) != 1 || !errors.As(gotErrs[0], &perr) {
+			t.Errorf("%s: error handler was called with %v, want a single *PanicError", tc.desc, gotErrs)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestProcessorArchivesTaskWithEmptyType(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	m := h.NewTaskMessage("", nil)
+	h.SeedPendingQueue(t, r, []*base.TaskMessage{m}, base.DefaultQueueName)
+
+	var (
+		mu     sync.Mutex
+		called bool
+	)
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+		mu.Lock()
+		defer mu.Unlock()
+		called = true
+		return nil
+	})
+	p := newProcessorForTest(t, rdbClient, handler)
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(2 * time.Second)
+	p.shutdown()
+
+	got := h.GetArchivedMessages(t, r, base.DefaultQueueName)
+	if len(got) != 1 || got[0].ID != m.ID || got[0].ErrorMsg != errEmptyTaskType.Error() {
+		t.Errorf("%q has %v, want the task archived with error %q", base.ArchivedKey(base.DefaultQueueName), got, errEmptyTaskType)
+	}
+	mu.Lock()
+	if called {
+		t.Error("handler was called for a task with empty type")
+	}
+	mu.Unlock()
+}
+
+func TestProcessorArchivesTaskPastDeadline(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	m := h.NewTaskMessage("send_notification", nil)
+	m.Timeout = 0
+	m.Deadline = time.Now().Add(-time.Minute).Unix()
+	h.SeedPendingQueue(t, r, []*base.TaskMessage{m}, base.DefaultQueueName)
+
+	var (
+		mu     sync.Mutex
+		called bool
+	)
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+		mu.Lock()
+		defer mu.Unlock()
+		called = true
+		return nil
+	})
+	p := newProcessorForTest(t, rdbClient, handler)
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(2 * time.Second)
+	p.shutdown()
+
+	if got := h.GetRetryMessages(t, r, base.DefaultQueueName); len(got) != 0 {
+		t.Errorf("%q has %v, want no tasks to be retried after their deadline", base.RetryKey(base.DefaultQueueName), got)
+	}
+	got := h.GetArchivedMessages(t, r, base.DefaultQueueName)
+	if len(got) != 1 || got[0].ID != m.ID || got[0].ErrorMsg != context.DeadlineExceeded.Error() {
+		t.Errorf("%q has %v, want the task archived with error %q", base.ArchivedKey(base.DefaultQueueName), got, context.DeadlineExceeded)
+	}
+	mu.Lock()
+	if called {
+		t.Error("handler was called for a task past its deadline")
+	}
+	mu.Unlock()
+}
+
+func TestProcessorReschedule(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	m1 := h.NewTaskMessage("send_email", nil)
+	m1.Retried = m1.Retry // rescheduling should work even if retries are exhausted
+	m1.ErrorMsg = "previous failure"
+	m1.LastFailedAt = time.Now().Add(-time.Hour).Unix()
+	m2 := h.NewTaskMessage("gen_thumbnail", nil)
+	processAt := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		desc      string
+		err       error            // error returned by the handler
+		wantScore func() time.Time // time the task should be processed at, relative to when the processor runs
+	}{
+		{"RescheduleIn", RescheduleIn(time.Hour), func() time.Time { return time.Now().Add(time.Hour) }},
+		{"RescheduleAt", RescheduleAt(processAt), func() time.Time { return processAt }},
+		{"wrapped RescheduleIn", fmt.Errorf("rate limited: %w", RescheduleIn(time.Hour)), func() time.Time { return time.Now().Add(time.Hour) }},
+	}
+
+	for _, tc := range tests {
+		h.FlushDB(t, r)
+		h.SeedPendingQueue(t, r, []*base.TaskMessage{m1, m2}, base.DefaultQueueName)
+
+		var (
+			mu sync.Mutex // guards n
+			n  int        // number of times error handler is called
+		)
+		handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+			return tc.err
+		})
+		p := newProcessorForTest(t, rdbClient, handler)
+		p.errHandler = ErrorHandlerFunc(func(ctx context.Context, t *Task, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			n++
+		})
+
+		wantScore := tc.wantScore()
+		p.start(&sync.WaitGroup{})
+		time.Sleep(2 * time.Second)
+		p.shutdown()
+
+		gotScheduled := h.GetScheduledEntries(t, r, base.DefaultQueueName)
+		if len(gotScheduled) != 2 {
+			t.Errorf("%s: %q has %d tasks, want 2", tc.desc, base.ScheduledKey(base.DefaultQueueName), len(gotScheduled))
+		}
+		for _, z := range gotScheduled {
+			if diff := z.Score - wantScore.Unix(); diff < -3 || diff > 3 {
+				t.Errorf("%s: task %s is scheduled at %v, want %v", tc.desc, z.Message.ID, time.Unix(z.Score, 0), wantScore)
+			}
+			want := m1
+			if z.Message.ID == m2.ID {
+				want = m2
+			}
+			// The task message should be left as is.
+			if diff := cmp.Diff(want, z.Message); diff != "" {
+				t.Errorf("%s: rescheduled task message mismatch; (-want,+got)\n%s", tc.desc, diff)
+			}
+		}
+		if got := h.GetRetryMessages(t, r, base.DefaultQueueName); len(got) != 0 {
+			t.Errorf("%s: %q has %d tasks, want 0", tc.desc, base.RetryKey(base.DefaultQueueName), len(got))
+		}
+		if got := h.GetArchivedMessages(t, r, base.DefaultQueueName); len(got) != 0 {
+			t.Errorf("%s: %q has %d tasks, want 0", tc.desc, base.ArchivedKey(base.DefaultQueueName), len(got))
+		}
+		mu.Lock()
+		if n != 0 {
+			t.Errorf("%s: error handler was called %d times, want 0", tc.desc, n)
+		}
+		mu.Unlock()
+	}
+}
+
 func TestProcessorMarkAsComplete(t *testing.T) {
 	r := setup(t)
 	defer r.Close()
@@ -622,6 +857,30 @@ func TestProcessorQueues(t *testing.T) {
 	}
 }
 
+func TestProcessorQueuesWeightedDistribution(t *testing.T) {
+	// Note: rdb and handler not needed for this test.
+	p := newProcessorForTest(t, nil, nil)
+	p.queueConfig = map[string]int{
+		"high":    6,
+		"default": 3,
+		"low":     1,
+	}
+
+	const n = 10000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[p.queues()[0]]++
+	}
+
+	// The queue checked first should be chosen in proportion to its priority.
+	for qname, want := range map[string]float64{"high": 0.6, "default": 0.3, "low": 0.1} {
+		got := float64(counts[qname]) / n
+		if math.Abs(got-want) > 0.05 {
+			t.Errorf("queue %q was checked first %.1f%% of the time, want about %.1f%%", qname, got*100, want*100)
+		}
+	}
+}
+
 func TestProcessorWithStrictPriority(t *testing.T) {
 	var (
 		r = setup(t)
@@ -726,6 +985,276 @@ func TestProcessorWithStrictPriority(t *testing.T) {
 	}
 }
 
+func TestProcessorSetConcurrency(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	var pending []*base.TaskMessage
+	for i := 0; i < 10; i++ {
+		pending = append(pending, h.NewTaskMessage("task", nil))
+	}
+	h.SeedPendingQueue(t, r, pending, base.DefaultQueueName)
+
+	var (
+		mu      sync.Mutex
+		running int
+	)
+	release := make(chan struct{})
+	handler := func(ctx context.Context, task *Task) error {
+		mu.Lock()
+		running++
+		mu.Unlock()
+		<-release
+		return nil
+	}
+	getRunning := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return running
+	}
+
+	p := newProcessorForTest(t, rdbClient, HandlerFunc(handler))
+	p.setConcurrency(2)
+	p.start(&sync.WaitGroup{})
+
+	time.Sleep(time.Second)
+	if got := getRunning(); got != 2 {
+		t.Errorf("with concurrency 2, %d tasks are running, want 2", got)
+	}
+	if got := p.activeWorkers(); got != 2 {
+		t.Errorf("with concurrency 2, activeWorkers() = %d, want 2", got)
+	}
+
+	p.setConcurrency(5)
+	time.Sleep(time.Second)
+	if got := getRunning(); got != 5 {
+		t.Errorf("after raising concurrency to 5, %d tasks are running, want 5", got)
+	}
+
+	p.setConcurrency(1)
+	time.Sleep(time.Second)
+	if got := getRunning(); got != 5 {
+		t.Errorf("after lowering concurrency to 1, %d tasks are running, want active tasks to keep running (5)", got)
+	}
+
+	close(release)
+	p.shutdown()
+	if got := p.activeWorkers(); got != 0 {
+		t.Errorf("after shutdown, activeWorkers() = %d, want 0", got)
+	}
+}
+
+func TestProcessorWithQueueRateLimits(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	var pending []*base.TaskMessage
+	for i := 0; i < 20; i++ {
+		pending = append(pending, h.NewTaskMessage("task", nil))
+	}
+	h.SeedPendingQueue(t, r, pending, base.DefaultQueueName)
+
+	var (
+		mu        sync.Mutex
+		processed int
+	)
+	handler := func(ctx context.Context, task *Task) error {
+		mu.Lock()
+		processed++
+		mu.Unlock()
+		return nil
+	}
+	p := newProcessorForTest(t, rdbClient, HandlerFunc(handler))
+	p.rateLimiters = map[string]*rate.Limiter{
+		base.DefaultQueueName: rate.NewLimiter(5, 1),
+	}
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(2 * time.Second)
+	p.shutdown()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 1 task allowed by the burst, plus 5 tasks per second.
+	if processed < 5 || processed > 12 {
+		t.Errorf("processed %d tasks in 2 seconds with rate limit of 5 tasks per second, want between 5 and 12", processed)
+	}
+}
+
+func TestProcessorReserveQueues(t *testing.T) {
+	p := newProcessorForTest(t, nil, nil)
+	p.rateLimiters = map[string]*rate.Limiter{
+		"limited": rate.NewLimiter(1, 1),
+	}
+	qnames := []string{"limited", "default"}
+
+	got, reservations, _, delay := p.reserveQueues(qnames)
+	if diff := cmp.Diff([]string{"limited", "default"}, got); diff != "" {
+		t.Errorf("first reserveQueues(%v) returned allowed queues diff (-want, +got):\n%s", qnames, diff)
+	}
+	if _, ok := reservations["limited"]; !ok || len(reservations) != 1 {
+		t.Errorf("first reserveQueues(%v) returned reservations for %v, want only \"limited\"", qnames, reservations)
+	}
+	if delay != 0 {
+		t.Errorf("first reserveQueues(%v) returned delay %v, want 0", qnames, delay)
+	}
+
+	got, reservations, _, delay = p.reserveQueues(qnames)
+	if diff := cmp.Diff([]string{"default"}, got); diff != "" {
+		t.Errorf("second reserveQueues(%v) returned allowed queues diff (-want, +got):\n%s", qnames, diff)
+	}
+	if len(reservations) != 0 {
+		t.Errorf("second reserveQueues(%v) returned reservations for %v, want none", qnames, reservations)
+	}
+	if delay <= 0 || delay > time.Second {
+		t.Errorf("second reserveQueues(%v) returned delay %v, want (0, 1s]", qnames, delay)
+	}
+}
+
+func TestProcessorGivesBackUnusedRateLimitTokens(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	tests := []struct {
+		desc    string
+		pending map[string][]*base.TaskMessage
+	}{
+		{"all queues are empty", map[string][]*base.TaskMessage{}},
+		{"task is dequeued from another queue", map[string][]*base.TaskMessage{
+			"default": {h.NewTaskMessageWithQueue("task1", nil, "default")},
+		}},
+	}
+
+	for _, tc := range tests {
+		h.FlushDB(t, r)
+		h.SeedAllPendingQueues(t, r, tc.pending)
+
+		handler := HandlerFunc(func(ctx context.Context, task *Task) error { return nil })
+		p := newProcessorForTest(t, rdbClient, handler)
+		p.queueConfig = map[string]int{"limited": 1, "default": 1}
+		p.taskCheckInterval = 10 * time.Millisecond
+		// A single token which is not replenished during the test.
+		lim := rate.NewLimiter(rate.Every(time.Hour), 1)
+		p.rateLimiters = map[string]*rate.Limiter{"limited": lim}
+
+		p.exec()
+
+		// Wait for the worker (if any) to finish.
+		for i := 0; i < 100 && p.activeWorkers() > 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !lim.Allow() {
+			t.Errorf("%s: rate limit token of queue %q was not given back", tc.desc, "limited")
+		}
+	}
+}
+
+func TestProcessorRecoversFromPanicInRetryDelayFunc(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	m1 := h.NewTaskMessage("task1", nil)
+	h.SeedPendingQueue(t, r, []*base.TaskMessage{m1}, base.DefaultQueueName)
+
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+		return fmt.Errorf("something went wrong")
+	})
+	p := newProcessorForTest(t, rdbClient, handler)
+	p.retryDelayFunc = func(n int, err error, task *Task) time.Duration {
+		panic("retry delay func panicked")
+	}
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(time.Second)
+	p.shutdown() // should not hang or crash
+
+	if n := p.activeWorkers(); n != 0 {
+		t.Errorf("activeWorkers() = %d, want 0", n)
+	}
+	// The task is left for the recoverer to retry once its lease expires.
+	if got := h.GetActiveMessages(t, r, base.DefaultQueueName); len(got) != 1 {
+		t.Errorf("%q has %d tasks, want 1", base.ActiveKey(base.DefaultQueueName), len(got))
+	}
+}
+
+func TestDequeueErrBackoff(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{6, 3200 * time.Millisecond},
+		{7, 5 * time.Second},
+		{100, 5 * time.Second},
+	}
+
+	for _, tc := range tests {
+		if got := dequeueErrBackoff(tc.n); got != tc.want {
+			t.Errorf("dequeueErrBackoff(%d) = %v, want %v", tc.n, got, tc.want)
+		}
+	}
+}
+
+// warnRecorder is a log.Base which records messages logged at Warn level.
+type warnRecorder struct {
+	mu    sync.Mutex
+	warns []string
+}
+
+func (r *warnRecorder) Debug(args ...interface{}) {}
+func (r *warnRecorder) Info(args ...interface{})  {}
+func (r *warnRecorder) Error(args ...interface{}) {}
+func (r *warnRecorder) Fatal(args ...interface{}) {}
+
+func (r *warnRecorder) Warn(args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warns = append(r.warns, fmt.Sprint(args...))
+}
+
+func TestProcessorSlowTaskThreshold(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	slow := h.NewTaskMessage("slow_task", nil)
+	fast := h.NewTaskMessage("fast_task", nil)
+	h.SeedPendingQueue(t, r, []*base.TaskMessage{slow, fast}, base.DefaultQueueName)
+
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error {
+		if task.Type() == "slow_task" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return nil
+	})
+	rec := &warnRecorder{}
+	p := newProcessorForTest(t, rdbClient, handler)
+	p.logger = log.NewLogger(rec)
+	p.slowTaskThreshold = 100 * time.Millisecond
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(time.Second)
+	p.shutdown()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var slowWarns []string
+	for _, w := range rec.warns {
+		if strings.HasPrefix(w, "Slow task") {
+			slowWarns = append(slowWarns, w)
+		}
+	}
+	if len(slowWarns) != 1 || !strings.Contains(slowWarns[0], slow.ID) {
+		t.Errorf("got slow task warnings %v, want exactly one for task %s", slowWarns, slow.ID)
+	}
+}
+
 func TestProcessorPerform(t *testing.T) {
 	tests := []struct {
 		desc    string
@@ -776,6 +1305,32 @@ func TestProcessorPerform(t *testing.T) {
 	}
 }
 
+func TestProcessorPerformPanicError(t *testing.T) {
+	p := newProcessorForTest(t, nil, nil)
+	p.handler = HandlerFunc(func(ctx context.Context, t *Task) error {
+		panic("something went terribly wrong")
+	})
+
+	got := p.perform(context.Background(), NewTask("gen_thumbnail", nil))
+
+	var perr *PanicError
+	if !errors.As(got, &perr) {
+		t.Fatalf("perform() = %v, want error of type *PanicError", got)
+	}
+	if perr.Value != "something went terribly wrong" {
+		t.Errorf("PanicError.Value = %v, want %q", perr.Value, "something went terribly wrong")
+	}
+	if perr.File == "" || !strings.HasSuffix(perr.File, "processor_test.go") {
+		t.Errorf("PanicError.File = %q, want file to be processor_test.go", perr.File)
+	}
+	if len(perr.Stack) == 0 {
+		t.Errorf("PanicError.Stack is empty, want stack trace")
+	}
+	if strings.Contains(perr.Error(), "goroutine") {
+		t.Errorf("PanicError.Error() = %q, want message without stack trace", perr.Error())
+	}
+}
+
 func TestGCD(t *testing.T) {
 	tests := []struct {
 		input []int
@@ -921,3 +1476,46 @@ func TestProcessorComputeDeadline(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessorExecRecoversFromPanic(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error { return nil })
+	p := newProcessorForTest(t, rdb.NewRDB(r), handler)
+	p.broker = nil // calling Dequeue on a nil broker panics
+
+	start := time.Now()
+	p.exec() // should not panic
+	p.exec()
+	// exec backs off after each panic, for 100ms and then 200ms.
+	if d := time.Since(start); d < 300*time.Millisecond {
+		t.Errorf("two panicking calls to exec took %v, want at least 300ms of backoff", d)
+	}
+	if p.execPanicCount != 2 {
+		t.Errorf("execPanicCount = %d, want 2", p.execPanicCount)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.numActive != 0 {
+		t.Errorf("numActive = %d after a panic in exec, want 0", p.numActive)
+	}
+}
+
+func TestProcessorShutdownDoesNotWaitForTaskCheckInterval(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	handler := HandlerFunc(func(ctx context.Context, task *Task) error { return nil })
+	p := newProcessorForTest(t, rdb.NewRDB(r), handler)
+	p.taskCheckInterval = 30 * time.Second
+
+	p.start(&sync.WaitGroup{})
+	time.Sleep(100 * time.Millisecond) // let the processor find the queues empty
+
+	start := time.Now()
+	p.stop()
+	p.shutdown()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("stop and shutdown took %v with empty queues, want less than 1s", d)
+	}
+}
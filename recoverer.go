@@ -82,6 +82,7 @@ func (r *recoverer) start(wg *sync.WaitGroup) {
 var ErrLeaseExpired = errors.New("asynq: task lease expired")
 
 func (r *recoverer) recover() {
+	defer recoverPanic(r.logger, "recoverer")
 	r.recoverLeaseExpiredTasks()
 	r.recoverStaleAggregationSets()
 }
@@ -94,6 +95,11 @@ func (r *recoverer) recoverLeaseExpiredTasks() {
 		r.logger.Warnf("recoverer: could not list lease expired tasks: %v", err)
 		return
 	}
+	if len(msgs) > 0 {
+		// Leases only expire when the worker stops sending heartbeats,
+		// which usually means that a server did not shut down cleanly.
+		r.logger.Warnf("recoverer: recovering %d task(s) with an expired lease", len(msgs))
+	}
 	for _, msg := range msgs {
 		if msg.Retried >= msg.Retry {
 			r.archive(msg, ErrLeaseExpired)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/hibiken/asynq/internal/base"
+	"github.com/hibiken/asynq/internal/log"
 	"github.com/hibiken/asynq/internal/rdb"
 	h "github.com/hibiken/asynq/internal/testutil"
 )
@@ -274,3 +275,35 @@ func TestRecoverer(t *testing.T) {
 		}
 	}
 }
+
+func TestRecovererLogsRecoveredTaskCount(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	rdbClient := rdb.NewRDB(r)
+
+	t1 := h.NewTaskMessageWithQueue("task1", nil, "default")
+	t2 := h.NewTaskMessageWithQueue("task2", nil, "default")
+	h.SeedActiveQueue(t, r, []*base.TaskMessage{t1, t2}, "default")
+	h.SeedLease(t, r, []base.Z{
+		{Message: t1, Score: time.Now().Add(-1 * time.Minute).Unix()},
+		{Message: t2, Score: time.Now().Add(-1 * time.Minute).Unix()},
+	}, "default")
+
+	rec := &warnRecorder{}
+	recoverer := newRecoverer(recovererParams{
+		logger:         log.NewLogger(rec),
+		broker:         rdbClient,
+		queues:         []string{"default"},
+		interval:       1 * time.Second,
+		retryDelayFunc: func(n int, err error, task *Task) time.Duration { return 30 * time.Second },
+		isFailureFunc:  defaultIsFailureFunc,
+	})
+	recoverer.recover()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	want := "recoverer: recovering 2 task(s) with an expired lease"
+	if len(rec.warns) != 1 || rec.warns[0] != want {
+		t.Errorf("logged warnings %v, want [%q]", rec.warns, want)
+	}
+}
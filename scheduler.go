@@ -43,6 +43,9 @@ type Scheduler struct {
 	// to avoid using cron.EntryID as the public API of
 	// the Scheduler.
 	idmap map[string]cron.EntryID
+	// When a Scheduler has been created with an existing Redis connection, we do
+	// not want to close it.
+	sharedConnection bool
 }
 
 // NewScheduler returns a new Scheduler instance given the redis connection option.
@@ -52,6 +55,16 @@ func NewScheduler(r RedisConnOpt, opts *SchedulerOpts) *Scheduler {
 	if !ok {
 		panic(fmt.Sprintf("asynq: unsupported RedisConnOpt type %T", r))
 	}
+	scheduler := NewSchedulerFromRedisClient(c, opts)
+	scheduler.sharedConnection = false
+	return scheduler
+}
+
+// NewSchedulerFromRedisClient returns a new Scheduler instance given a redis client.
+// The parameter opts is optional, defaults will be used if opts is set to nil.
+// Warning: The underlying redis connection pool will not be closed by Asynq,
+// you are responsible for closing it.
+func NewSchedulerFromRedisClient(c redis.UniversalClient, opts *SchedulerOpts) *Scheduler {
 	if opts == nil {
 		opts = &SchedulerOpts{}
 	}
@@ -69,18 +82,19 @@ func NewScheduler(r RedisConnOpt, opts *SchedulerOpts) *Scheduler {
 	}
 
 	return &Scheduler{
-		id:              generateSchedulerID(),
-		state:           &serverState{value: srvStateNew},
-		logger:          logger,
-		client:          NewClient(r),
-		rdb:             rdb.NewRDB(c),
-		cron:            cron.New(cron.WithLocation(loc), cron.WithSeconds()),
-		location:        loc,
-		done:            make(chan struct{}),
-		preEnqueueFunc:  opts.PreEnqueueFunc,
-		postEnqueueFunc: opts.PostEnqueueFunc,
-		errHandler:      opts.EnqueueErrorHandler,
-		idmap:           make(map[string]cron.EntryID),
+		id:               generateSchedulerID(),
+		state:            &serverState{value: srvStateNew},
+		logger:           logger,
+		client:           NewClientFromRedisClient(c),
+		rdb:              rdb.NewRDB(c),
+		cron:             cron.New(cron.WithLocation(loc), cron.WithSeconds()),
+		location:         loc,
+		done:             make(chan struct{}),
+		preEnqueueFunc:   opts.PreEnqueueFunc,
+		postEnqueueFunc:  opts.PostEnqueueFunc,
+		errHandler:       opts.EnqueueErrorHandler,
+		idmap:            make(map[string]cron.EntryID),
+		sharedConnection: true,
 	}
 }
 
@@ -261,8 +275,10 @@ func (s *Scheduler) Shutdown() {
 	s.wg.Wait()
 
 	s.clearHistory()
-	s.client.Close()
-	s.rdb.Close()
+	// s.client shares the connection with s.rdb.
+	if !s.sharedConnection {
+		s.rdb.Close()
+	}
 	s.logger.Info("Scheduler stopped")
 }
 
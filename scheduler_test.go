@@ -5,6 +5,7 @@
 package asynq
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -208,3 +209,19 @@ func TestSchedulerPostAndPreEnqueueHandler(t *testing.T) {
 	}
 	postMu.Unlock()
 }
+
+func TestSchedulerFromRedisClientDoesNotCloseConnection(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	scheduler := NewSchedulerFromRedisClient(r, &SchedulerOpts{LogLevel: testLogLevel})
+	if _, err := scheduler.Register("@every 1s", NewTask("task1", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Start(); err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Shutdown()
+	if err := r.Ping(context.Background()).Err(); err != nil {
+		t.Errorf("redis client returned error %v after Shutdown, want the connection to stay open", err)
+	}
+}
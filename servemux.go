@@ -6,6 +6,7 @@ package asynq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -23,10 +24,11 @@ import (
 // "images:thumbnails" and the former will receive tasks with type name beginning
 // with "images".
 type ServeMux struct {
-	mu  sync.RWMutex
-	m   map[string]muxEntry
-	es  []muxEntry // slice of entries sorted from longest to shortest.
-	mws []MiddlewareFunc
+	mu       sync.RWMutex
+	m        map[string]muxEntry
+	es       []muxEntry // slice of entries sorted from longest to shortest.
+	mws      []MiddlewareFunc
+	notFound Handler // handler to use if no pattern matches; NotFoundHandler if nil.
 }
 
 type muxEntry struct {
@@ -57,14 +59,18 @@ func (mux *ServeMux) ProcessTask(ctx context.Context, task *Task) error {
 // Handler also returns the registered pattern that matches the task.
 //
 // If there is no registered handler that applies to the task,
-// handler returns a 'not found' handler which returns an error.
+// handler returns the handler registered with HandleNotFound, or
+// a 'not found' handler which returns an error if none is registered.
 func (mux *ServeMux) Handler(t *Task) (h Handler, pattern string) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
 	h, pattern = mux.match(t.Type())
 	if h == nil {
-		h, pattern = NotFoundHandler(), ""
+		h, pattern = mux.notFound, ""
+		if h == nil {
+			h = NotFoundHandler()
+		}
 	}
 	for i := len(mux.mws) - 1; i >= 0; i-- {
 		h = mux.mws[i](h)
@@ -139,6 +145,19 @@ func (mux *ServeMux) HandleFunc(pattern string, handler func(context.Context, *T
 	mux.Handle(pattern, HandlerFunc(handler))
 }
 
+// HandleNotFound registers the handler to use for tasks whose type
+// does not match any registered pattern.
+// If HandleNotFound is not called, NotFoundHandler is used.
+func (mux *ServeMux) HandleNotFound(handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("asynq: nil handler")
+	}
+	mux.notFound = handler
+}
+
 // Use appends a MiddlewareFunc to the chain.
 // Middlewares are executed in the order that they are applied to the ServeMux.
 func (mux *ServeMux) Use(mws ...MiddlewareFunc) {
@@ -149,9 +168,29 @@ func (mux *ServeMux) Use(mws ...MiddlewareFunc) {
 	}
 }
 
+// ErrHandlerNotFound indicates that no handler is registered for the task type.
+//
+// The error returned by NotFound matches both ErrHandlerNotFound and SkipRetry
+// when checked with errors.Is, so that the task is archived instead of retried.
+var ErrHandlerNotFound = errors.New("handler not found")
+
+// handlerNotFoundError is the error returned by NotFound.
+type handlerNotFoundError struct {
+	typename string
+}
+
+func (e *handlerNotFoundError) Error() string {
+	return fmt.Sprintf("handler not found for task %q", e.typename)
+}
+
+func (e *handlerNotFoundError) Is(target error) bool {
+	return target == ErrHandlerNotFound || target == SkipRetry
+}
+
 // NotFound returns an error indicating that the handler was not found for the given task.
+// See ErrHandlerNotFound.
 func NotFound(ctx context.Context, task *Task) error {
-	return fmt.Errorf("handler not found for task %q", task.Type())
+	return &handlerNotFoundError{typename: task.Type()}
 }
 
 // NotFoundHandler returns a simple task handler that returns a ``not found`` error.
@@ -6,6 +6,7 @@ package asynq
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -126,6 +127,35 @@ func TestServeMuxNotFound(t *testing.T) {
 		if err == nil {
 			t.Errorf("ProcessTask did not return error for task %q, should return 'not found' error", task.Type())
 		}
+		if !errors.Is(err, ErrHandlerNotFound) {
+			t.Errorf("ProcessTask returned %v for task %q, want error matching ErrHandlerNotFound", err, task.Type())
+		}
+		if !errors.Is(err, SkipRetry) {
+			t.Errorf("ProcessTask returned %v for task %q, want error matching SkipRetry", err, task.Type())
+		}
+	}
+}
+
+func TestServeMuxHandleNotFound(t *testing.T) {
+	mux := NewServeMux()
+	for _, e := range serveMuxRegister {
+		mux.Handle(e.pattern, e.h)
+	}
+	var gotTypes []string
+	mux.HandleNotFound(HandlerFunc(func(ctx context.Context, task *Task) error {
+		gotTypes = append(gotTypes, task.Type())
+		return nil
+	}))
+
+	for _, tc := range notFoundTests {
+		task := NewTask(tc.typename, nil)
+		if err := mux.ProcessTask(context.Background(), task); err != nil {
+			t.Errorf("ProcessTask returned %v for task %q, want nil from registered not found handler", err, task.Type())
+		}
+	}
+	want := []string{"image:minimize", "csv:"}
+	if diff := cmp.Diff(want, gotTypes); diff != "" {
+		t.Errorf("not found handler called with unexpected tasks; (-want,+got)\n%s", diff)
 	}
 }
 
@@ -19,6 +19,7 @@ import (
 	"github.com/hibiken/asynq/internal/base"
 	"github.com/hibiken/asynq/internal/log"
 	"github.com/hibiken/asynq/internal/rdb"
+	"golang.org/x/time/rate"
 )
 
 // Server is responsible for task processing and task lifecycle management.
@@ -37,9 +38,16 @@ type Server struct {
 	logger *log.Logger
 
 	broker base.Broker
+	// When a Server has been created with an existing Redis connection, we do
+	// not want to close it.
+	sharedConnection bool
 
 	state *serverState
 
+	// concurrencyMu serializes calls to SetConcurrency, so that the processor
+	// and the heartbeater always end up with the same value.
+	concurrencyMu sync.Mutex
+
 	// wait group to wait for all goroutines to finish.
 	wg            sync.WaitGroup
 	forwarder     *forwarder
@@ -146,9 +154,38 @@ type Config struct {
 	// higher priorities are empty.
 	StrictPriority bool
 
+	// QueueRateLimits optionally limits the rate at which the server pulls tasks off of each queue.
+	// Keys are the names of the queues and values specify the limit for the queue.
+	// Queues without an entry, or with a zero or negative Limit, are not rate limited.
+	// Entries for queues that are not in Queues are ignored, and a warning is logged for each of them.
+	//
+	// When a queue has used up its limit, the server skips the queue and pulls tasks
+	// from other queues until the limit allows another task to be processed.
+	//
+	// Limits are enforced by each server independently. If multiple servers process
+	// the same queue, the overall rate is the sum of the limits of those servers.
+	//
+	// Example:
+	//
+	//     QueueRateLimits: map[string]asynq.RateLimit{
+	//         "external_api": {Limit: 100, Burst: 10},
+	//     }
+	//
+	// With the above config, tasks in "external_api" queue are processed at most
+	// 100 times per second on average, with bursts of up to 10 tasks.
+	QueueRateLimits map[string]RateLimit
+
+	// PanicBehavior specifies what to do with a task whose handler panicked.
+	//
+	// The stack trace of the panic is logged regardless of the behavior.
+	// If unset, PanicRetry is used, which retries the task up to its max retry count.
+	PanicBehavior PanicBehavior
+
 	// ErrorHandler handles errors returned by the task handler.
 	//
 	// HandleError is invoked only if the task handler returns a non-nil error.
+	// If HandleError panics, the panic is recovered and logged, and the task is
+	// retried or archived as usual.
 	//
 	// Example:
 	//
@@ -180,6 +217,13 @@ type Config struct {
 	// If unset or zero, default timeout of 8 seconds is used.
 	ShutdownTimeout time.Duration
 
+	// SlowTaskThreshold specifies the duration after which a call to the Handler
+	// is considered slow. Each slow call is logged at Warn level with the task
+	// type and the time it took.
+	//
+	// If unset, zero or a negative value, slow calls are not logged.
+	SlowTaskThreshold time.Duration
+
 	// HealthCheckFunc is called periodically with any errors encountered during ping to the
 	// connected redis server.
 	HealthCheckFunc func(error)
@@ -189,9 +233,24 @@ type Config struct {
 	// If unset or zero, the interval is set to 15 seconds.
 	HealthCheckInterval time.Duration
 
+	// TaskCheckInterval specifies the interval between checks for new tasks to process
+	// when all queues are empty.
+	//
+	// Shorter intervals let the server start newly enqueued tasks sooner after a quiet period,
+	// at the cost of more load on redis since queues are polled rather than read with blocking commands.
+	//
+	// The wait is cut short when the server stops, so longer intervals do not delay Stop or Shutdown.
+	// They only delay the pickup of tasks enqueued while the server is idle, by up to one interval.
+	//
+	// If unset, zero or a negative value, the interval is set to 1 second.
+	TaskCheckInterval time.Duration
+
 	// DelayedTaskCheckInterval specifies the interval between checks run on 'scheduled' and 'retry'
 	// tasks, and forwarding them to 'pending' state if they are ready to be processed.
 	//
+	// A task may wait up to this interval after its scheduled time before it is moved
+	// to 'pending' state. Shorter intervals reduce this delay at the cost of more load on redis.
+	//
 	// If unset or zero, the interval is set to 5 seconds.
 	DelayedTaskCheckInterval time.Duration
 
@@ -242,6 +301,31 @@ func (fn GroupAggregatorFunc) Aggregate(group string, tasks []*Task) *Task {
 	return fn(group, tasks)
 }
 
+// PanicBehavior specifies how the server handles a task whose handler panicked.
+//
+// In either case the ErrorHandler, if set, is called with a *PanicError.
+type PanicBehavior int
+
+const (
+	// PanicRetry retries the task like any other failed task,
+	// until it reaches its max retry count.
+	PanicRetry PanicBehavior = iota
+
+	// PanicArchive archives the task without retrying it,
+	// to keep a task that always panics from using up worker capacity.
+	PanicArchive
+)
+
+// RateLimit specifies a token bucket rate limit for a queue.
+type RateLimit struct {
+	// Limit is the maximum average number of tasks processed per second.
+	Limit float64
+
+	// Burst is the maximum number of tasks that can be processed at once.
+	// If zero or negative, a burst of one task is used.
+	Burst int
+}
+
 // An ErrorHandler handles an error occurred during task processing.
 type ErrorHandler interface {
 	HandleError(ctx context.Context, task *Task, err error)
@@ -384,6 +468,8 @@ const (
 
 	defaultHealthCheckInterval = 15 * time.Second
 
+	defaultTaskCheckInterval = 1 * time.Second
+
 	defaultDelayedTaskCheckInterval = 5 * time.Second
 
 	defaultGroupGracePeriod = 1 * time.Minute
@@ -396,6 +482,16 @@ func NewServer(r RedisConnOpt, cfg Config) *Server {
 	if !ok {
 		panic(fmt.Sprintf("asynq: unsupported RedisConnOpt type %T", r))
 	}
+	server := NewServerFromRedisClient(c, cfg)
+	server.sharedConnection = false
+	return server
+}
+
+// NewServerFromRedisClient returns a new Server given a redis client
+// and server configuration.
+// Warning: The underlying redis connection pool will not be closed by Asynq,
+// you are responsible for closing it.
+func NewServerFromRedisClient(c redis.UniversalClient, cfg Config) *Server {
 	baseCtxFn := cfg.BaseContext
 	if baseCtxFn == nil {
 		baseCtxFn = context.Background
@@ -451,6 +547,22 @@ func NewServer(r RedisConnOpt, cfg Config) *Server {
 	}
 	logger.SetLevel(toInternalLogLevel(loglevel))
 
+	rateLimiters := make(map[string]*rate.Limiter)
+	for qname, rl := range cfg.QueueRateLimits {
+		if _, ok := queues[qname]; !ok {
+			logger.Warnf("Ignoring rate limit for queue %q: the server does not process the queue", qname)
+			continue
+		}
+		if rl.Limit <= 0 {
+			continue
+		}
+		burst := rl.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		rateLimiters[qname] = rate.NewLimiter(rate.Limit(rl.Limit), burst)
+	}
+
 	rdb := rdb.NewRDB(c)
 	starting := make(chan *workerInfo)
 	finished := make(chan *base.TaskMessage)
@@ -490,20 +602,24 @@ func NewServer(r RedisConnOpt, cfg Config) *Server {
 		cancelations: cancels,
 	})
 	processor := newProcessor(processorParams{
-		logger:          logger,
-		broker:          rdb,
-		retryDelayFunc:  delayFunc,
-		baseCtxFn:       baseCtxFn,
-		isFailureFunc:   isFailureFunc,
-		syncCh:          syncCh,
-		cancelations:    cancels,
-		concurrency:     n,
-		queues:          queues,
-		strictPriority:  cfg.StrictPriority,
-		errHandler:      cfg.ErrorHandler,
-		shutdownTimeout: shutdownTimeout,
-		starting:        starting,
-		finished:        finished,
+		logger:            logger,
+		broker:            rdb,
+		retryDelayFunc:    delayFunc,
+		baseCtxFn:         baseCtxFn,
+		isFailureFunc:     isFailureFunc,
+		syncCh:            syncCh,
+		cancelations:      cancels,
+		concurrency:       n,
+		queues:            queues,
+		strictPriority:    cfg.StrictPriority,
+		rateLimiters:      rateLimiters,
+		errHandler:        cfg.ErrorHandler,
+		panicBehavior:     cfg.PanicBehavior,
+		shutdownTimeout:   shutdownTimeout,
+		taskCheckInterval: cfg.TaskCheckInterval,
+		slowTaskThreshold: cfg.SlowTaskThreshold,
+		starting:          starting,
+		finished:          finished,
 	})
 	recoverer := newRecoverer(recovererParams{
 		logger:         logger,
@@ -535,18 +651,19 @@ func NewServer(r RedisConnOpt, cfg Config) *Server {
 		groupAggregator: cfg.GroupAggregator,
 	})
 	return &Server{
-		logger:        logger,
-		broker:        rdb,
-		state:         srvState,
-		forwarder:     forwarder,
-		processor:     processor,
-		syncer:        syncer,
-		heartbeater:   heartbeater,
-		subscriber:    subscriber,
-		recoverer:     recoverer,
-		healthchecker: healthchecker,
-		janitor:       janitor,
-		aggregator:    aggregator,
+		logger:           logger,
+		broker:           rdb,
+		sharedConnection: true,
+		state:            srvState,
+		forwarder:        forwarder,
+		processor:        processor,
+		syncer:           syncer,
+		heartbeater:      heartbeater,
+		subscriber:       subscriber,
+		recoverer:        recoverer,
+		healthchecker:    healthchecker,
+		janitor:          janitor,
+		aggregator:       aggregator,
 	}
 }
 
@@ -562,6 +679,12 @@ func NewServer(r RedisConnOpt, cfg Config) *Server {
 // One exception to this rule is when ProcessTask returns a SkipRetry error.
 // If the returned error is SkipRetry or an error wraps SkipRetry, retry is
 // skipped and the task will be immediately archived instead.
+//
+// The context passed to ProcessTask is canceled when the task's timeout or
+// deadline is exceeded, when the task is canceled via Inspector.CancelProcessing,
+// or when the server is shutting down and the ShutdownTimeout has elapsed.
+// Long running handlers should watch ctx.Done() and check ctx.Err() to stop
+// processing early; the server does not forcibly stop a running handler.
 type Handler interface {
 	ProcessTask(context.Context, *Task) error
 }
@@ -648,6 +771,9 @@ func (srv *Server) start() error {
 // It gracefully closes all active workers. The server will wait for
 // active workers to finish processing tasks for duration specified in Config.ShutdownTimeout.
 // If worker didn't finish processing a task during the timeout, the task will be pushed back to Redis.
+//
+// Shutdown returns after all workers have exited and the server has removed its state from Redis,
+// so resources used by handlers (e.g. a database pool) can be released right after it returns.
 func (srv *Server) Shutdown() {
 	srv.state.mu.Lock()
 	if srv.state.value == srvStateNew || srv.state.value == srvStateClosed {
@@ -674,7 +800,9 @@ func (srv *Server) Shutdown() {
 	srv.heartbeater.shutdown()
 	srv.wg.Wait()
 
-	srv.broker.Close()
+	if !srv.sharedConnection {
+		srv.broker.Close()
+	}
 	srv.logger.Info("Exiting")
 }
 
@@ -697,3 +825,40 @@ func (srv *Server) Stop() {
 	srv.processor.stop()
 	srv.logger.Info("Processor stopped")
 }
+
+// Ping performs a ping against the redis connection.
+//
+// This is an alternative to the HealthCheckFunc available in the Config object,
+// and can be used to implement a readiness or liveness probe.
+// Ping returns nil if the server has been shutdown.
+func (srv *Server) Ping() error {
+	srv.state.mu.Lock()
+	defer srv.state.mu.Unlock()
+	if srv.state.value == srvStateClosed {
+		return nil
+	}
+	return srv.broker.Ping()
+}
+
+// ActiveWorkers returns the number of workers currently processing a task.
+//
+// Use Inspector.GetQueueInfo to get the number of tasks in each state of a queue.
+func (srv *Server) ActiveWorkers() int {
+	return srv.processor.activeWorkers()
+}
+
+// SetConcurrency changes the maximum number of concurrent processing of tasks
+// while the server is running. If n is zero or negative, the number of CPUs
+// usable by the current process is used, as with Config.Concurrency.
+//
+// Lowering the concurrency does not stop active workers; the server stops
+// pulling new tasks off queues until enough of them finish to get below the new limit.
+func (srv *Server) SetConcurrency(n int) {
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+	srv.concurrencyMu.Lock()
+	defer srv.concurrencyMu.Unlock()
+	srv.processor.setConcurrency(n)
+	srv.heartbeater.setConcurrency(n)
+}
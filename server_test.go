@@ -7,10 +7,13 @@ package asynq
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/hibiken/asynq/internal/base"
 	"github.com/hibiken/asynq/internal/rdb"
 	"github.com/hibiken/asynq/internal/testbroker"
 	"github.com/hibiken/asynq/internal/testutil"
@@ -117,6 +120,91 @@ func TestServerErrServerRunning(t *testing.T) {
 	srv.Shutdown()
 }
 
+func TestServerPing(t *testing.T) {
+	srv := NewServer(getRedisConnOpt(t), Config{LogLevel: testLogLevel})
+	if err := srv.Start(NewServeMux()); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Ping(); err != nil {
+		t.Errorf("Ping() = %v, want nil", err)
+	}
+	srv.Shutdown()
+	if err := srv.Ping(); err != nil {
+		t.Errorf("Ping() after Shutdown = %v, want nil", err)
+	}
+}
+
+func TestServerFromRedisClientDoesNotCloseConnection(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	srv := NewServerFromRedisClient(r, Config{LogLevel: testLogLevel})
+	if err := srv.Start(NewServeMux()); err != nil {
+		t.Fatal(err)
+	}
+	srv.Shutdown()
+	if err := r.Ping(context.Background()).Err(); err != nil {
+		t.Errorf("redis client returned error %v after Shutdown, want the connection to stay open", err)
+	}
+}
+
+func TestServerWarnsAboutRateLimitForUnknownQueue(t *testing.T) {
+	rec := &warnRecorder{}
+	srv := NewServer(getRedisConnOpt(t), Config{
+		Logger:          rec,
+		Queues:          map[string]int{"default": 1},
+		QueueRateLimits: map[string]RateLimit{"defualt": {Limit: 10}},
+	})
+	defer srv.broker.Close()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.warns) != 1 || !strings.Contains(rec.warns[0], `"defualt"`) {
+		t.Errorf("logged warnings %v, want one warning about queue %q", rec.warns, "defualt")
+	}
+}
+
+func TestServerSetConcurrency(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	srv := NewServerFromRedisClient(r, Config{Concurrency: 10, LogLevel: testLogLevel})
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			srv.SetConcurrency(n)
+		}(i)
+	}
+	wg.Wait()
+	srv.processor.mu.Lock()
+	processorConcurrency := srv.processor.concurrency
+	srv.processor.mu.Unlock()
+	srv.heartbeater.mu.Lock()
+	heartbeatConcurrency := srv.heartbeater.concurrency
+	srv.heartbeater.mu.Unlock()
+	if processorConcurrency != heartbeatConcurrency {
+		t.Errorf("after concurrent calls to SetConcurrency, processor has concurrency %d and heartbeater has %d, want them equal",
+			processorConcurrency, heartbeatConcurrency)
+	}
+
+	srv.SetConcurrency(3)
+	srv.heartbeater.beat()
+	servers, err := rdb.NewRDB(r).ListServers()
+	if err != nil {
+		t.Fatalf("ListServers returned error: %v", err)
+	}
+	if len(servers) != 1 || servers[0].Concurrency != 3 {
+		t.Errorf("ListServers() = %v, want one server with concurrency 3", servers)
+	}
+}
+
+func TestServerPingWithRedisDown(t *testing.T) {
+	srv := NewServer(RedisClientOpt{Addr: ":1234"}, Config{LogLevel: testLogLevel})
+	if err := srv.Ping(); err == nil {
+		t.Error("Ping() = nil, want non-nil error when redis is unreachable")
+	}
+}
+
 func TestServerWithRedisDown(t *testing.T) {
 	// Make sure that server does not panic and exit if redis is down.
 	defer func() {
@@ -210,6 +298,64 @@ func TestServerWithFlakyBroker(t *testing.T) {
 	srv.Shutdown()
 }
 
+func TestServerShutdownDoesNotLoseTasks(t *testing.T) {
+	r := setup(t)
+	defer r.Close()
+	redisConnOpt := getRedisConnOpt(t)
+	c := NewClient(redisConnOpt)
+	defer c.Close()
+
+	const numTasks = 100
+	var enqueued []string
+	for i := 0; i < numTasks; i++ {
+		info, err := c.Enqueue(NewTask("task", nil))
+		if err != nil {
+			t.Fatalf("could not enqueue a task: %v", err)
+		}
+		enqueued = append(enqueued, info.ID)
+	}
+
+	var (
+		mu        sync.Mutex
+		processed = make(map[string]bool)
+	)
+	h := func(ctx context.Context, task *Task) error {
+		time.Sleep(10 * time.Millisecond)
+		id, _ := GetTaskID(ctx)
+		mu.Lock()
+		processed[id] = true
+		mu.Unlock()
+		return nil
+	}
+
+	srv := NewServer(redisConnOpt, Config{
+		Concurrency:     10,
+		LogLevel:        testLogLevel,
+		ShutdownTimeout: time.Second,
+	})
+	if err := srv.Start(HandlerFunc(h)); err != nil {
+		t.Fatal(err)
+	}
+	// Shut down while workers are dequeueing and processing tasks.
+	time.Sleep(50 * time.Millisecond)
+	srv.Shutdown()
+
+	remaining := make(map[string]bool)
+	for _, msg := range testutil.GetPendingMessages(t, r, base.DefaultQueueName) {
+		remaining[msg.ID] = true
+	}
+	for _, msg := range testutil.GetActiveMessages(t, r, base.DefaultQueueName) {
+		remaining[msg.ID] = true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range enqueued {
+		if !processed[id] && !remaining[id] {
+			t.Errorf("task %s was neither processed nor left in the queue after shutdown", id)
+		}
+	}
+}
+
 func TestLogLevel(t *testing.T) {
 	tests := []struct {
 		flagVal string
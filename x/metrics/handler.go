@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HandlerMetricsCollector gathers metrics about tasks processed by a Handler.
+// It implements prometheus.Collector interface.
+//
+// Metrics are recorded by the middleware returned from Middleware, which
+// should be registered with the ServeMux of every server to monitor.
+// All metrics exported from this collector have prefix "asynq_handler".
+type HandlerMetricsCollector struct {
+	processed *prometheus.CounterVec
+	failed    *prometheus.CounterVec
+	retried   *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+}
+
+// NewHandlerMetricsCollector returns a collector that exports metrics about task processing.
+func NewHandlerMetricsCollector() *HandlerMetricsCollector {
+	labels := []string{"queue", "task_type"}
+	return &HandlerMetricsCollector{
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "tasks_processed_total",
+			Help:      "Number of tasks processed by the handler (both succeeded and failed); broken down by queue and task type.",
+		}, labels),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "tasks_failed_total",
+			Help:      "Number of tasks for which the handler returned an error; broken down by queue and task type.",
+		}, labels),
+		retried: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "tasks_retried_total",
+			Help:      "Number of tasks processed by the handler that were retries of an earlier failed attempt; broken down by queue and task type.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "processing_duration_seconds",
+			Help:      "Number of seconds the handler took to process a task; broken down by queue and task type.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+	}
+}
+
+// Middleware returns a middleware that records metrics for each task processed by the wrapped handler.
+func (hmc *HandlerMetricsCollector) Middleware(h asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		qname, _ := asynq.GetQueueName(ctx)
+		start := time.Now()
+		err := h.ProcessTask(ctx, t)
+		hmc.duration.WithLabelValues(qname, t.Type()).Observe(time.Since(start).Seconds())
+		hmc.processed.WithLabelValues(qname, t.Type()).Inc()
+		if n, _ := asynq.GetRetryCount(ctx); n > 0 {
+			hmc.retried.WithLabelValues(qname, t.Type()).Inc()
+		}
+		if err != nil {
+			hmc.failed.WithLabelValues(qname, t.Type()).Inc()
+		}
+		return err
+	})
+}
+
+func (hmc *HandlerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	hmc.processed.Describe(ch)
+	hmc.failed.Describe(ch)
+	hmc.retried.Describe(ch)
+	hmc.duration.Describe(ch)
+}
+
+func (hmc *HandlerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	hmc.processed.Collect(ch)
+	hmc.failed.Collect(ch)
+	hmc.retried.Collect(ch)
+	hmc.duration.Collect(ch)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/hibiken/asynq/internal/base"
+	asynqcontext "github.com/hibiken/asynq/internal/context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandlerMetricsCollector(t *testing.T) {
+	hmc := NewHandlerMetricsCollector()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(hmc); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	h := hmc.Middleware(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		if t.Type() == "bad_task" {
+			return errors.New("something went wrong")
+		}
+		return nil
+	}))
+
+	ctx := context.Background()
+	h.ProcessTask(ctx, asynq.NewTask("good_task", nil))
+	h.ProcessTask(ctx, asynq.NewTask("good_task", nil))
+	h.ProcessTask(ctx, asynq.NewTask("bad_task", nil))
+	// a retry of good_task in the "critical" queue.
+	retryCtx, cancel := asynqcontext.New(&base.TaskMessage{Type: "good_task", Queue: "critical", Retried: 1}, time.Now().Add(time.Minute))
+	defer cancel()
+	h.ProcessTask(retryCtx, asynq.NewTask("good_task", nil))
+
+	tests := []struct {
+		desc string
+		c    prometheus.Collector
+		want float64
+	}{
+		{"processed good_task", hmc.processed.WithLabelValues("", "good_task"), 2},
+		{"processed bad_task", hmc.processed.WithLabelValues("", "bad_task"), 1},
+		{"failed good_task", hmc.failed.WithLabelValues("", "good_task"), 0},
+		{"failed bad_task", hmc.failed.WithLabelValues("", "bad_task"), 1},
+		{"processed critical good_task", hmc.processed.WithLabelValues("critical", "good_task"), 1},
+		{"retried good_task", hmc.retried.WithLabelValues("", "good_task"), 0},
+		{"retried critical good_task", hmc.retried.WithLabelValues("critical", "good_task"), 1},
+	}
+	for _, tc := range tests {
+		if got := testutil.ToFloat64(tc.c); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+
+	if n, err := testutil.GatherAndCount(reg, "asynq_handler_processing_duration_seconds"); err != nil || n != 3 {
+		t.Errorf("GatherAndCount(asynq_handler_processing_duration_seconds) = %d, %v; want 3, nil", n, err)
+	}
+}